@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Fornax96/sia_benchmark/collector"
+	"github.com/Fornaxian/log"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	sia "gitlab.com/NebulousLabs/Sia/node/api/client"
+)
+
+// siaFileList records the siapaths of the files uploaded during a run, so they
+// can be removed from the renter with the cleanup command. The siapaths are
+// also kept in memory, so the files of the current run can be recognized
+type siaFileList struct {
+	mutex sync.Mutex
+	file  *os.File
+	paths map[modules.SiaPath]bool
+}
+
+func openSiaFileList(path string) (*siaFileList, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &siaFileList{file: f, paths: make(map[modules.SiaPath]bool)}, nil
+}
+
+func (l *siaFileList) add(siaPath modules.SiaPath) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.paths[siaPath] = true
+	_, err := fmt.Fprintln(l.file, siaPath.String())
+	return err
+}
+
+// contains returns whether a siapath was uploaded during this run
+func (l *siaFileList) contains(siaPath modules.SiaPath) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.paths[siaPath]
+}
+
+// readSiaFileList reads the siapaths which were uploaded during a run
+func readSiaFileList(path string) (siaPaths []modules.SiaPath, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var scanner = bufio.NewScanner(f)
+	for scanner.Scan() {
+		siaPath, err := modules.NewSiaPath(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("invalid siapath '%s': %s", scanner.Text(), err)
+		}
+		siaPaths = append(siaPaths, siaPath)
+	}
+	return siaPaths, scanner.Err()
+}
+
+var cleanupOpts struct {
+	dryRun bool
+	runDir string
+	all    bool
+	force  bool
+}
+
+func cleanupFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&cleanupOpts.dryRun, "dry_run", false,
+		"Only list the files which would be removed")
+	fs.StringVar(&cleanupOpts.runDir, "run", "",
+		"Also remove the siafiles uploaded by the run in this run directory from the renter")
+	fs.BoolVar(&cleanupOpts.all, "all", false,
+		"Also remove all siafiles uploaded by the benchmark tool from the renter")
+	fs.BoolVar(&cleanupOpts.force, "force", false,
+		"Remove the local files even if the renter can't be asked which files it is still uploading")
+}
+
+// cmdCleanup removes the files which were left in the upload queue by a
+// previous run. Optionally it also removes the siafiles of a single run or of
+// all runs from the renter. It refuses to run while a test answers on the
+// control socket, and files which the renter is still uploading are kept
+func cmdCleanup(conf Configuration, fs *flag.FlagSet) {
+	if cleanupOpts.runDir != "" && cleanupOpts.all {
+		log.Error("The -run and -all flags can't be used together")
+		os.Exit(2)
+	}
+	if testRunning(conf) {
+		log.Error("A test is running on %s, stop it before cleaning up", conf.ControlSocket)
+		os.Exit(1)
+	}
+	var sc = newSiaClient(conf)
+
+	// Local files
+	files, err := ioutil.ReadDir(conf.FileUploadsDir)
+	if err != nil {
+		log.Error("Could not read upload queue: %s", err)
+		os.Exit(1)
+	}
+	uploading, err := uploadingLocalFiles(sc)
+	if err != nil && !cleanupOpts.force {
+		log.Error("Could not list the files on the renter, use -force to remove all local files anyway: %s", err)
+		os.Exit(1)
+	} else if err != nil {
+		log.Warn("Could not list the files on the renter, removing all local files: %s", err)
+	}
+	var localFiles []string
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".dat" {
+			continue
+		}
+		var path = filepath.Join(conf.FileUploadsDir, file.Name())
+		if abs, err := filepath.Abs(path); err == nil && uploading[abs] {
+			log.Warn("Keeping %s, the renter is still uploading it", path)
+			continue
+		}
+		localFiles = append(localFiles, path)
+	}
+
+	var removed int
+	for i, path := range localFiles {
+		if cleanupOpts.dryRun {
+			fmt.Printf("Would remove local file %s\n", path)
+			continue
+		}
+		if err = os.Remove(path); err != nil {
+			log.Warn("Could not remove '%s': %s", path, err)
+			continue
+		}
+		removed++
+		fmt.Printf("[%d/%d] Removed local file %s\n", i+1, len(localFiles), path)
+	}
+	if !cleanupOpts.dryRun {
+		log.Info("Removed %d files from %s", removed, conf.FileUploadsDir)
+	}
+
+	if cleanupOpts.runDir == "" && !cleanupOpts.all {
+		return
+	}
+
+	// Siafiles on the renter
+	var siaPaths []modules.SiaPath
+	if cleanupOpts.all {
+		if strings.Trim(siaPathPrefix(conf), "/") == "" {
+			log.Warn("siapath_root has no directory before {run_id}, only files of older versions of the tool are removed")
+		}
+		siaPaths, err = collector.BenchmarkSiaFiles(sc, siaPathPrefix(conf))
+	} else {
+		siaPaths, err = readSiaFileList(filepath.Join(cleanupOpts.runDir, "siafiles.txt"))
+	}
+	if err != nil {
+		log.Error("Could not list siafiles to remove: %s", err)
+		os.Exit(1)
+	}
+
+	removed = 0
+	for i, siaPath := range siaPaths {
+		if cleanupOpts.dryRun {
+			fmt.Printf("Would remove siafile %s\n", siaPath)
+			continue
+		}
+		if err = sc.RenterDeletePost(siaPath); err != nil {
+			log.Warn("Could not remove siafile '%s': %s", siaPath, err)
+			continue
+		}
+		removed++
+		fmt.Printf("[%d/%d] Removed siafile %s\n", i+1, len(siaPaths), siaPath)
+	}
+	if !cleanupOpts.dryRun {
+		log.Info("Removed %d siafiles from the renter", removed)
+	}
+}
+
+// uploadingLocalFiles returns the local paths of the files which the renter is
+// still uploading
+func uploadingLocalFiles(sc *sia.Client) (map[string]bool, error) {
+	var uploading = make(map[string]bool)
+	files, err := sc.RenterFilesGet(false)
+	if err != nil {
+		return uploading, err
+	}
+	for _, file := range files.Files {
+		if file.UploadProgress < 100 {
+			uploading[filepath.Clean(file.LocalPath)] = true
+		}
+	}
+	return uploading, nil
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/Fornax96/sia_benchmark/report"
+	"github.com/Fornaxian/config"
+	"github.com/Fornaxian/log"
+)
+
+// command is a subcommand of the benchmark tool
+type command struct {
+	name  string
+	args  string
+	usage string
+
+	// Whether the config file needs to be loaded before running the command
+	loadConfig bool
+
+	// Registers the flags which are specific to this command, can be nil
+	flags func(fs *flag.FlagSet)
+
+	run func(conf Configuration, fs *flag.FlagSet)
+}
+
+var commands = []command{
+	{"run", "", "Run the benchmark", true, nil, cmdRun},
+	{"watch", "", "Monitor the Sia node without uploading anything", true, nil, cmdWatch},
+	{"report", "<run dir>", "Write an HTML report with charts of a run", false, reportFlags, cmdReport},
+	{"compare", "<run dir> <run dir>...", "Compare the results of two or more runs", false, compareFlags, cmdCompare},
+	{"cleanup", "", "Remove benchmark files from the upload queue and the renter", true, cleanupFlags, cmdCleanup},
+	{"verify", "", "Check the configuration and run the preflight checks", true, nil, cmdVerify},
+	{"control", "status|pause|resume|collect|set <option> <value>|stop [reason]",
+		"Control a running test through control_socket", true, nil, cmdControl},
+	{"config init", "", "Write the default configuration file", false, nil, cmdConfigInit},
+}
+
+// runCommand parses the command line and runs the requested subcommand. If no
+// subcommand is given the benchmark is started, like before subcommands
+// existed
+func runCommand(args []string) {
+	var name = "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+		if name == "config" && len(args) > 0 {
+			name, args = name+" "+args[0], args[1:]
+		}
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+
+		var fs = flag.NewFlagSet(cmd.name, flag.ExitOnError)
+		// Commands which don't load the config would ignore these flags
+		var confPath *string
+		var overrides configFlags
+		if cmd.loadConfig || cmd.name == "config init" {
+			confPath = fs.String("config", "benchmark.toml", "Path of the configuration file")
+		}
+		if cmd.loadConfig {
+			overrides = addConfigFlags(fs)
+		}
+		if cmd.flags != nil {
+			cmd.flags(fs)
+		}
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] %s\n\n%s\n\nFlags:\n",
+				filepath.Base(os.Args[0]), cmd.name, cmd.args, cmd.usage)
+			fs.PrintDefaults()
+		}
+		fs.Parse(args)
+
+		var explicit bool
+		fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "config" })
+
+		var conf Configuration
+		if cmd.loadConfig {
+			conf = loadConfig(*confPath, explicit, overrides)
+		}
+		cmd.run(conf, fs)
+		return
+	}
+
+	if name != "help" {
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'\n\n", name)
+	}
+	printUsage()
+	if name != "help" {
+		os.Exit(2)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\n"+
+		"Every field in the configuration file can be overridden with a flag of\n"+
+		"the same name for the commands which use the configuration, for\n"+
+		"example -max_concurrent_uploads 20. Run\n"+
+		"'%s <command> -h' to see all flags\n", filepath.Base(os.Args[0]))
+}
+
+// loadConfig reads the configuration file and applies the overrides from the
+// command line. A config file which was given with -config is loaded from that
+// path only. Otherwise the default config locations are searched, and if no
+// config file exists a default one is generated and the program exits
+func loadConfig(path string, explicit bool, overrides configFlags) (conf Configuration) {
+	if explicit {
+		// Values which are missing from the file keep their defaults
+		if _, err := toml.Decode(defaultConfig, &conf); err != nil {
+			panic(err)
+		}
+		if _, err := toml.DecodeFile(path, &conf); err != nil {
+			log.Error("Could not load configuration file %s: %s", path, err)
+			os.Exit(1)
+		}
+	} else if _, err := config.New(defaultConfig, "", filepath.Base(path), &conf, true); err != nil {
+		panic(err)
+	}
+	if err := overrides.apply(&conf); err != nil {
+		log.Error("Invalid flag: %s", err)
+		os.Exit(2)
+	}
+	log.SetLogLevel(conf.LoggingVerbosity)
+	return conf
+}
+
+// configFlags contains the configuration values which were set on the command
+// line, by TOML name. They are applied after the config file is loaded so they
+// take precedence over it
+type configFlags map[string]string
+
+// addConfigFlags adds a flag for every field in the Configuration struct. The
+// flags have the same name as the fields in the config file
+func addConfigFlags(fs *flag.FlagSet) configFlags {
+	var overrides = make(configFlags)
+	var t = reflect.TypeOf(Configuration{})
+	for i := 0; i < t.NumField(); i++ {
+		var field = t.Field(i)
+		var name = field.Tag.Get("toml")
+		fs.Var(
+			configFlag{name: name, typ: field.Type, overrides: overrides},
+			name,
+			"Overrides "+name+" from the config file",
+		)
+	}
+	return overrides
+}
+
+// apply writes the overridden values to the configuration
+func (overrides configFlags) apply(conf *Configuration) error {
+	var v = reflect.ValueOf(conf).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		var name = v.Type().Field(i).Tag.Get("toml")
+		if value, ok := overrides[name]; ok {
+			if err := setConfigField(v.Field(i), value); err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setConfigField parses a string and stores it in a configuration field
+func setConfigField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Kind())
+	}
+	return nil
+}
+
+// configFlag is a flag.Value which records the value in the overrides map
+type configFlag struct {
+	name      string
+	typ       reflect.Type
+	overrides configFlags
+}
+
+func (f configFlag) String() string { return "" }
+
+func (f configFlag) Set(value string) error {
+	// Check if the value can be parsed before accepting it
+	if err := setConfigField(reflect.New(f.typ).Elem(), value); err != nil {
+		return err
+	}
+	f.overrides[f.name] = value
+	return nil
+}
+
+func (f configFlag) IsBoolFlag() bool { return f.typ.Kind() == reflect.Bool }
+
+func cmdRun(conf Configuration, fs *flag.FlagSet) {
+	mustValidate(conf)
+	runBenchmark(conf)
+}
+
+func cmdWatch(conf Configuration, fs *flag.FlagSet) {
+	conf.WatchOnly = true
+	mustValidate(conf)
+	runBenchmark(conf)
+}
+
+var reportOpts struct {
+	output string
+}
+
+func reportFlags(fs *flag.FlagSet) {
+	fs.StringVar(&reportOpts.output, "output", "",
+		"Path of the HTML report, defaults to report.html in the run directory")
+}
+
+// cmdReport prints the final results of a run and writes an HTML report with
+// charts of the metrics, which can be shared without any extra tools
+func cmdReport(conf Configuration, fs *flag.FlagSet) {
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	var runDir = fs.Arg(0)
+
+	run, err := report.LoadRun(runDir)
+	if err != nil {
+		log.Error("Could not load run: %s", err)
+		os.Exit(1)
+	}
+	if run.FiatErr != nil {
+		log.Warn("Spending is only shown in siacoins, %s", run.FiatErr)
+	}
+
+	var summary = report.Summarize(run)
+	fmt.Printf("%-20s  %s\n", "Run ID", summary.RunID)
+	fmt.Printf("%-20s  %s\n", "Duration", summary.Duration.Round(time.Second))
+	fmt.Printf("%-20s  %s\n", "Exit reason", summary.ExitReason)
+	fmt.Printf("%-20s  %s\n", "File data", formatData(summary.FileTotalBytes))
+	fmt.Printf("%-20s  %s\n", "Contract data", formatData(summary.ContractSizeTotal))
+	fmt.Printf("%-20s  %.2f%%\n", "Efficiency", summary.Efficiency*100)
+
+	var output = reportOpts.output
+	if output == "" {
+		output = filepath.Join(runDir, "report.html")
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		log.Error("Could not create report: %s", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	if err = report.WriteHTML(f, run); err != nil {
+		log.Error("Could not write report: %s", err)
+		os.Exit(1)
+	}
+	log.Info("Report written to %s", output)
+}
+
+var compareOpts struct {
+	align  string
+	output string
+}
+
+func compareFlags(fs *flag.FlagSet) {
+	fs.StringVar(&compareOpts.align, "align", report.AlignTime,
+		"Align the runs by elapsed \"time\" or by uploaded \"bytes\"")
+	fs.StringVar(&compareOpts.output, "output", "compare.html",
+		"Path of the HTML comparison")
+}
+
+// cmdCompare compares the results of two or more runs. The first run is used
+// as the baseline
+func cmdCompare(conf Configuration, fs *flag.FlagSet) {
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if compareOpts.align != report.AlignTime && compareOpts.align != report.AlignBytes {
+		log.Error("-align must be \"%s\" or \"%s\", not \"%s\"",
+			report.AlignTime, report.AlignBytes, compareOpts.align)
+		os.Exit(2)
+	}
+
+	var comparison = report.Comparison{Align: compareOpts.align}
+	for _, dir := range fs.Args() {
+		run, err := report.LoadRun(dir)
+		if err != nil {
+			log.Error("Could not load run %s: %s", dir, err)
+			os.Exit(1)
+		}
+		if run.FiatErr != nil {
+			log.Warn("Spending of run %s is only shown in siacoins, %s", dir, run.FiatErr)
+		}
+		comparison.Runs = append(comparison.Runs, run)
+	}
+
+	if err := comparison.WriteText(os.Stdout); err != nil {
+		log.Error("Could not print comparison: %s", err)
+		os.Exit(1)
+	}
+
+	f, err := os.Create(compareOpts.output)
+	if err != nil {
+		log.Error("Could not create comparison: %s", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	if err = comparison.WriteHTML(f); err != nil {
+		log.Error("Could not write comparison: %s", err)
+		os.Exit(1)
+	}
+	log.Info("Comparison written to %s", compareOpts.output)
+}
+
+// cmdVerify validates the configuration, checks if the Sia node can be reached
+// and runs the preflight checks
+func cmdVerify(conf Configuration, fs *flag.FlagSet) {
+	mustValidate(conf)
+	log.Info("Configuration is valid")
+
+	version, err := newSiaClient(conf).DaemonVersionGet()
+	if err != nil {
+		log.Error("Could not connect to Sia at %s: %s", conf.SiaAPIURL, err)
+		os.Exit(1)
+	}
+	log.Info("Connected to Sia %s (rev %s)", version.Version, version.GitRevision)
+
+	if failed := printPreflight(preflight(conf, newSiaClient(conf))); failed > 0 {
+		log.Warn("%d preflight check(s) failed", failed)
+		os.Exit(1)
+	}
+}
+
+// cmdConfigInit writes the default configuration to the config path
+func cmdConfigInit(conf Configuration, fs *flag.FlagSet) {
+	var path = fs.Lookup("config").Value.String()
+	if _, err := os.Stat(path); err == nil {
+		log.Error("Configuration file %s already exists", path)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(path, []byte(defaultConfig), 0644); err != nil {
+		log.Error("Could not write configuration file: %s", err)
+		os.Exit(1)
+	}
+	log.Info("Wrote default configuration to %s", path)
+}
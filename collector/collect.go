@@ -9,14 +9,20 @@ import (
 
 // CollectMetrics collects stats on the Files, Contracts, Wallet and Allowance
 // of the Sia node. It stores a summary of all the information in the Metrics
-// struct and returns it
-func CollectMetrics(sc *sia.Client) (metrics Metrics, err error) {
+// struct and returns it. If an upload tracker is passed the upload latency of
+// the tracked files will be included in the metrics. The contracts are returned
+// as well, so the other collectors can use the same snapshot
+func CollectMetrics(sc *sia.Client, tracker *UploadTracker) (
+	metrics Metrics,
+	contracts api.RenterContracts,
+	err error,
+) {
 	metrics.Timestamp = time.Now()
 
 	// Collect file stats
 	files, err := sc.RenterFilesGet(true)
 	if err != nil {
-		return metrics, err
+		return metrics, contracts, err
 	}
 	for _, file := range files.Files {
 		metrics.FileTotalBytes += uint64(float64(file.Filesize) * (file.UploadProgress / 100))
@@ -26,11 +32,16 @@ func CollectMetrics(sc *sia.Client) (metrics Metrics, err error) {
 			metrics.FileUploadsInProgressCount++
 		}
 	}
+	fillFileHealth(&metrics, files.Files)
+	if tracker != nil {
+		tracker.update(files.Files, metrics.Timestamp)
+		tracker.fillMetrics(&metrics)
+	}
 
 	// Collect contract stats
-	contracts, err := sc.RenterAllContractsGet()
+	contracts, err = sc.RenterAllContractsGet()
 	if err != nil {
-		return metrics, err
+		return metrics, contracts, err
 	}
 
 	var addTotals = func(contract api.RenterContract, countSize bool) {
@@ -111,10 +122,14 @@ func CollectMetrics(sc *sia.Client) (metrics Metrics, err error) {
 		Add(metrics.ContractUploadSpendingTotal).
 		Add(metrics.ContractDownloadSpendingTotal)
 
+	if metrics.ContractSizeTotal > 0 {
+		metrics.Efficiency = float64(metrics.FileTotalBytes) / float64(metrics.ContractSizeTotal)
+	}
+
 	// Collect wallet stats
 	wallet, err := sc.WalletGet()
 	if err != nil {
-		return metrics, err
+		return metrics, contracts, err
 	}
 	metrics.WalletSiacoinBalance = wallet.ConfirmedSiacoinBalance
 	metrics.WalletOutgoingSiacoins = wallet.UnconfirmedOutgoingSiacoins
@@ -123,9 +138,10 @@ func CollectMetrics(sc *sia.Client) (metrics Metrics, err error) {
 	// Collect renter stats
 	renter, err := sc.RenterGet()
 	if err != nil {
-		return metrics, err
+		return metrics, contracts, err
 	}
 	metrics.RenterAllowance = renter.Settings.Allowance.Funds
+	metrics.RenterAllowancePeriod = renter.Settings.Allowance.Period
 	metrics.RenterContractFees = renter.FinancialMetrics.ContractFees
 	metrics.RenterTotalAllocated = renter.FinancialMetrics.TotalAllocated
 	metrics.RenterDownloadSpending = renter.FinancialMetrics.DownloadSpending
@@ -135,5 +151,31 @@ func CollectMetrics(sc *sia.Client) (metrics Metrics, err error) {
 
 	metrics.APILatency = time.Since(metrics.Timestamp)
 
-	return metrics, nil
+	return metrics, contracts, nil
+}
+
+// CollectChainMetrics collects the block height, sync status, peer count and
+// transaction pool fee estimates of the Sia node and adds them to the metrics
+func CollectChainMetrics(sc *sia.Client, metrics *Metrics) (err error) {
+	consensus, err := sc.ConsensusGet()
+	if err != nil {
+		return err
+	}
+	metrics.ConsensusHeight = consensus.Height
+	metrics.ConsensusSynced = consensus.Synced
+
+	gateway, err := sc.GatewayGet()
+	if err != nil {
+		return err
+	}
+	metrics.GatewayPeerCount = len(gateway.Peers)
+
+	fee, err := sc.TransactionPoolFeeGet()
+	if err != nil {
+		return err
+	}
+	metrics.TpoolFeeMinimum = fee.Minimum
+	metrics.TpoolFeeMaximum = fee.Maximum
+
+	return nil
 }
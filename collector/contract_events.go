@@ -0,0 +1,187 @@
+package collector
+
+import (
+	"encoding/csv"
+	"strconv"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/node/api"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// Contract lifecycle events
+const (
+	ContractFormed    = "formed"
+	ContractRenewed   = "renewed"
+	ContractDisabled  = "disabled"
+	ContractReenabled = "reenabled"
+	ContractExpired   = "expired"
+	ContractChanged   = "changed"
+)
+
+// Contract states as reported by the renter
+const (
+	stateActive           = "active"
+	statePassive          = "passive"
+	stateRefreshed        = "refreshed"
+	stateDisabled         = "disabled"
+	stateExpired          = "expired"
+	stateExpiredRefreshed = "expired_refreshed"
+)
+
+// ContractEvent describes a change in the contract set of the renter between
+// two collection rounds
+type ContractEvent struct {
+	Timestamp     time.Time `csv:"timestamp"`
+	Event         string    `csv:"event"`
+	ContractID    string    `csv:"contract_id"`
+	HostPublicKey string    `csv:"host_public_key"`
+	NetAddress    string    `csv:"net_address"`
+	OldState      string    `csv:"old_state"`
+	NewState      string    `csv:"new_state"`
+
+	// When a contract is renewed this is the ID of the contract it replaced
+	RenewedFrom string `csv:"renewed_from"`
+
+	Size        uint64         `csv:"size"`
+	TotalCost   types.Currency `csv:"total_cost"`
+	RenterFunds types.Currency `csv:"renter_funds"`
+}
+
+type watchedContract struct {
+	contract api.RenterContract
+	state    string
+}
+
+// ContractWatcher compares the contract sets returned by the renter between
+// collection rounds and reports the differences as events
+type ContractWatcher struct {
+	contracts map[types.FileContractID]watchedContract
+}
+
+// NewContractWatcher creates a new contract watcher. The first round only
+// records the existing contracts, events are reported from the second round on
+func NewContractWatcher() *ContractWatcher {
+	return &ContractWatcher{}
+}
+
+// Collect compares the contracts of the renter with the contracts from the
+// previous round and returns the events which happened in between
+func (cw *ContractWatcher) Collect(contracts api.RenterContracts, now time.Time) (events []ContractEvent) {
+	var current = make(map[types.FileContractID]watchedContract)
+	var addState = func(list []api.RenterContract, state string) {
+		for _, contract := range list {
+			current[contract.ID] = watchedContract{contract: contract, state: state}
+		}
+	}
+	addState(contracts.ActiveContracts, stateActive)
+	addState(contracts.PassiveContracts, statePassive)
+	addState(contracts.RefreshedContracts, stateRefreshed)
+	addState(contracts.DisabledContracts, stateDisabled)
+	addState(contracts.ExpiredContracts, stateExpired)
+	addState(contracts.ExpiredRefreshedContracts, stateExpiredRefreshed)
+
+	if cw.contracts == nil {
+		// First round, nothing to compare with
+		cw.contracts = current
+		return nil
+	}
+
+	var newEvent = func(event string, c watchedContract, oldState string) ContractEvent {
+		return ContractEvent{
+			Timestamp:     now,
+			Event:         event,
+			ContractID:    c.contract.ID.String(),
+			HostPublicKey: c.contract.HostPublicKey.String(),
+			NetAddress:    string(c.contract.NetAddress),
+			OldState:      oldState,
+			NewState:      c.state,
+			Size:          c.contract.Size,
+			TotalCost:     c.contract.TotalCost,
+			RenterFunds:   c.contract.RenterFunds,
+		}
+	}
+
+	// Contracts which were refreshed this round, by host. These are matched
+	// with the new contracts to detect renewals
+	var refreshed = make(map[string]watchedContract)
+	for id, c := range current {
+		if prev, ok := cw.contracts[id]; ok && c.state == stateRefreshed &&
+			prev.state != stateRefreshed {
+			refreshed[c.contract.HostPublicKey.String()] = c
+		}
+	}
+
+	for id, c := range current {
+		prev, ok := cw.contracts[id]
+		if !ok {
+			var host = c.contract.HostPublicKey.String()
+			if old, renewed := refreshed[host]; renewed {
+				var ev = newEvent(ContractRenewed, c, "")
+				ev.RenewedFrom = old.contract.ID.String()
+				events = append(events, ev)
+				delete(refreshed, host)
+			} else {
+				events = append(events, newEvent(ContractFormed, c, ""))
+			}
+			continue
+		}
+		if prev.state == c.state {
+			continue
+		}
+
+		switch c.state {
+		case stateRefreshed:
+			// Reported together with the new contract
+		case stateDisabled:
+			events = append(events, newEvent(ContractDisabled, c, prev.state))
+		case stateExpired, stateExpiredRefreshed:
+			events = append(events, newEvent(ContractExpired, c, prev.state))
+		default:
+			if prev.state == stateDisabled {
+				events = append(events, newEvent(ContractReenabled, c, prev.state))
+			} else {
+				events = append(events, newEvent(ContractChanged, c, prev.state))
+			}
+		}
+	}
+
+	// Refreshed contracts for which no new contract was found are still
+	// reported as renewals
+	for _, c := range refreshed {
+		events = append(events, newEvent(ContractRenewed, c, cw.contracts[c.contract.ID].state))
+	}
+
+	cw.contracts = current
+	return events
+}
+
+// ContractEventHeaders returns all the CSV headers of the ContractEvent struct
+func ContractEventHeaders() (headers []string) {
+	return csvHeaders(ContractEvent{})
+}
+
+// Values marshals all the values to a string and returns them in an array so
+// they can be written to the CSV
+func (e ContractEvent) Values() (values []string) {
+	return append(values,
+		e.Timestamp.UTC().Format("2006-01-02T15:04:05Z"),
+		e.Event,
+		e.ContractID,
+		e.HostPublicKey,
+		e.NetAddress,
+		e.OldState,
+		e.NewState,
+		e.RenewedFrom,
+		strconv.FormatUint(e.Size, 10),
+		e.TotalCost.String(),
+		e.RenterFunds.String(),
+	)
+}
+
+// WriteCSV adds a row to an existing CSV file with the stored values of
+// ContractEvent
+func (e ContractEvent) WriteCSV(f *csv.Writer) error {
+	defer f.Flush()
+	return f.Write(e.Values())
+}
@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/node/api"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// testContract creates a contract with an ID and a host which are easy to
+// recognize in the events
+func testContract(id, host byte) api.RenterContract {
+	var c = api.RenterContract{
+		HostPublicKey: types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{host}},
+	}
+	c.ID[0] = id
+	return c
+}
+
+func contractID(id byte) string {
+	return testContract(id, 0).ID.String()
+}
+
+// event is the part of a ContractEvent which is compared in the tests
+type event struct {
+	event, id, oldState, newState, renewedFrom string
+}
+
+func TestContractWatcher(t *testing.T) {
+	var a, b = testContract(1, 1), testContract(2, 1)
+	var c = testContract(3, 2)
+	var list = func(contracts ...api.RenterContract) []api.RenterContract { return contracts }
+
+	var tests = []struct {
+		name       string
+		prev, curr api.RenterContracts
+		want       []event
+	}{{
+		name: "unchanged",
+		prev: api.RenterContracts{ActiveContracts: list(a)},
+		curr: api.RenterContracts{ActiveContracts: list(a)},
+	}, {
+		name: "formed",
+		prev: api.RenterContracts{},
+		curr: api.RenterContracts{ActiveContracts: list(a)},
+		want: []event{{ContractFormed, contractID(1), "", stateActive, ""}},
+	}, {
+		name: "renewed with the same host",
+		prev: api.RenterContracts{ActiveContracts: list(a)},
+		curr: api.RenterContracts{ActiveContracts: list(b), RefreshedContracts: list(a)},
+		want: []event{{ContractRenewed, contractID(2), "", stateActive, contractID(1)}},
+	}, {
+		name: "refreshed without a new contract",
+		prev: api.RenterContracts{ActiveContracts: list(a)},
+		curr: api.RenterContracts{RefreshedContracts: list(a)},
+		want: []event{{ContractRenewed, contractID(1), stateActive, stateRefreshed, ""}},
+	}, {
+		name: "new contract with another host is not a renewal",
+		prev: api.RenterContracts{ActiveContracts: list(a)},
+		curr: api.RenterContracts{ActiveContracts: list(c), RefreshedContracts: list(a)},
+		want: []event{
+			{ContractRenewed, contractID(1), stateActive, stateRefreshed, ""},
+			{ContractFormed, contractID(3), "", stateActive, ""},
+		},
+	}, {
+		name: "disabled",
+		prev: api.RenterContracts{ActiveContracts: list(a)},
+		curr: api.RenterContracts{DisabledContracts: list(a)},
+		want: []event{{ContractDisabled, contractID(1), stateActive, stateDisabled, ""}},
+	}, {
+		name: "reenabled",
+		prev: api.RenterContracts{DisabledContracts: list(a)},
+		curr: api.RenterContracts{ActiveContracts: list(a)},
+		want: []event{{ContractReenabled, contractID(1), stateDisabled, stateActive, ""}},
+	}, {
+		name: "expired",
+		prev: api.RenterContracts{ActiveContracts: list(a), RefreshedContracts: list(c)},
+		curr: api.RenterContracts{ExpiredContracts: list(a), ExpiredRefreshedContracts: list(c)},
+		want: []event{
+			{ContractExpired, contractID(1), stateActive, stateExpired, ""},
+			{ContractExpired, contractID(3), stateRefreshed, stateExpiredRefreshed, ""},
+		},
+	}, {
+		name: "changed",
+		prev: api.RenterContracts{ActiveContracts: list(a)},
+		curr: api.RenterContracts{PassiveContracts: list(a)},
+		want: []event{{ContractChanged, contractID(1), stateActive, statePassive, ""}},
+	}}
+
+	// Events are compared in order of contract ID
+	var now = time.Now()
+	for _, test := range tests {
+		var cw = NewContractWatcher()
+		if events := cw.Collect(test.prev, now); len(events) != 0 {
+			t.Errorf("%s: first round returned events: %v", test.name, events)
+		}
+
+		var got []event
+		for _, e := range cw.Collect(test.curr, now) {
+			got = append(got, event{e.Event, e.ContractID, e.OldState, e.NewState, e.RenewedFrom})
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i].id < got[j].id })
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%s:\n got %v\nwant %v", test.name, got, test.want)
+		}
+	}
+}
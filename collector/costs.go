@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// blocksPerMonth is the number of blocks in a 30 day month, with one block
+// every ten minutes
+const blocksPerMonth = 30 * 24 * 6
+
+// bytesPerTB is used for normalizing the spending per TB of file data
+const bytesPerTB = 1e12
+
+// CalculateCosts normalizes the spending of the renter to the amount of file
+// data which was uploaded. All costs are per TB of file data, not contract
+// data, so the redundancy is included in the price:
+//
+//   - CostUploadPerTB is the upload spending per TB
+//   - CostStoragePerTBMonth is the storage spending per TB per month. Storage
+//     is paid for until the end of the allowance period
+//   - CostFeeOverheadPercent is the part of the total spending which went to
+//     contract fees
+//   - CostProjectedTotal is the total spending when the success size threshold
+//     is reached, if the current cost per TB stays the same. Zero if the
+//     threshold is disabled
+//
+// The costs are zero as long as no file data has been uploaded
+func CalculateCosts(metrics *Metrics, successSizeThreshold uint64) {
+	metrics.CostUploadPerTB = types.ZeroCurrency
+	metrics.CostStoragePerTBMonth = types.ZeroCurrency
+	metrics.CostFeeOverheadPercent = 0
+	metrics.CostProjectedTotal = types.ZeroCurrency
+
+	if !metrics.ContractSpendingTotal.IsZero() {
+		fees, _ := metrics.ContractFeeSpendingTotal.Float64()
+		total, _ := metrics.ContractSpendingTotal.Float64()
+		metrics.CostFeeOverheadPercent = fees / total * 100
+	}
+
+	if metrics.FileTotalBytes == 0 {
+		return
+	}
+	metrics.CostUploadPerTB = metrics.ContractUploadSpendingTotal.
+		Mul64(bytesPerTB).Div64(metrics.FileTotalBytes)
+	if metrics.RenterAllowancePeriod > 0 {
+		metrics.CostStoragePerTBMonth = metrics.ContractStorageSpendingTotal.
+			Mul64(bytesPerTB).Div64(metrics.FileTotalBytes).
+			Mul64(blocksPerMonth).Div64(uint64(metrics.RenterAllowancePeriod))
+	}
+	if successSizeThreshold > 0 {
+		metrics.CostProjectedTotal = metrics.ContractSpendingTotal.
+			Mul64(successSizeThreshold).Div64(metrics.FileTotalBytes)
+	}
+}
@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+func TestCalculateCosts(t *testing.T) {
+	var sc = types.SiacoinPrecision
+	var tests = []struct {
+		name      string
+		metrics   Metrics
+		threshold uint64
+
+		upload, storage, projected types.Currency
+		feePercent                 float64
+	}{{
+		name:    "nothing spent or uploaded",
+		metrics: Metrics{RenterAllowancePeriod: blocksPerMonth},
+	}, {
+		name: "fees before any upload",
+		metrics: Metrics{
+			ContractFeeSpendingTotal: sc.Mul64(10),
+			ContractSpendingTotal:    sc.Mul64(40),
+			RenterAllowancePeriod:    blocksPerMonth,
+		},
+		threshold:  bytesPerTB,
+		feePercent: 25,
+	}, {
+		name: "half a TB in one month",
+		metrics: Metrics{
+			FileTotalBytes:               bytesPerTB / 2,
+			ContractUploadSpendingTotal:  sc.Mul64(50),
+			ContractStorageSpendingTotal: sc.Mul64(100),
+			ContractFeeSpendingTotal:     sc.Mul64(50),
+			ContractSpendingTotal:        sc.Mul64(200),
+			RenterAllowancePeriod:        blocksPerMonth,
+		},
+		threshold:  2 * bytesPerTB,
+		upload:     sc.Mul64(100),
+		storage:    sc.Mul64(200),
+		projected:  sc.Mul64(800),
+		feePercent: 25,
+	}, {
+		name: "storage over three months",
+		metrics: Metrics{
+			FileTotalBytes:               bytesPerTB,
+			ContractStorageSpendingTotal: sc.Mul64(300),
+			ContractSpendingTotal:        sc.Mul64(300),
+			RenterAllowancePeriod:        3 * blocksPerMonth,
+		},
+		storage:   sc.Mul64(100),
+		projected: types.ZeroCurrency, // Threshold disabled
+	}, {
+		name: "no allowance period",
+		metrics: Metrics{
+			FileTotalBytes:               bytesPerTB,
+			ContractUploadSpendingTotal:  sc.Mul64(10),
+			ContractStorageSpendingTotal: sc.Mul64(300),
+			ContractSpendingTotal:        sc.Mul64(310),
+		},
+		threshold: bytesPerTB,
+		upload:    sc.Mul64(10),
+		projected: sc.Mul64(310),
+	}}
+
+	for _, test := range tests {
+		// Results of an earlier round are overwritten
+		var metrics = test.metrics
+		metrics.CostUploadPerTB = sc
+		metrics.CostStoragePerTBMonth = sc
+		metrics.CostFeeOverheadPercent = 50
+		metrics.CostProjectedTotal = sc
+
+		CalculateCosts(&metrics, test.threshold)
+		if metrics.CostUploadPerTB.Cmp(test.upload) != 0 {
+			t.Errorf("%s: upload cost is %s, want %s",
+				test.name, metrics.CostUploadPerTB.HumanString(), test.upload.HumanString())
+		}
+		if metrics.CostStoragePerTBMonth.Cmp(test.storage) != 0 {
+			t.Errorf("%s: storage cost is %s, want %s",
+				test.name, metrics.CostStoragePerTBMonth.HumanString(), test.storage.HumanString())
+		}
+		if metrics.CostProjectedTotal.Cmp(test.projected) != 0 {
+			t.Errorf("%s: projected cost is %s, want %s",
+				test.name, metrics.CostProjectedTotal.HumanString(), test.projected.HumanString())
+		}
+		if metrics.CostFeeOverheadPercent != test.feePercent {
+			t.Errorf("%s: fee overhead is %.2f%%, want %.2f%%",
+				test.name, metrics.CostFeeOverheadPercent, test.feePercent)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package collector
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// FreeSpace is not implemented on this operating system
+func FreeSpace(path string) (uint64, error) {
+	return 0, fmt.Errorf("checking free disk space is not supported on %s", runtime.GOOS)
+}
@@ -6,89 +6,163 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/Fornaxian/log"
+	"gitlab.com/NebulousLabs/Sia/crypto"
 	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
 	"gitlab.com/NebulousLabs/Sia/node/api"
 	sia "gitlab.com/NebulousLabs/Sia/node/api/client"
 	"gitlab.com/NebulousLabs/fastrand"
 	"lukechampine.com/frand"
 )
 
-func newSiaPath(name string) (siaPath modules.SiaPath) {
-	siaPath, err := modules.NewSiaPath(
-		string(name[0:2]) + "/" + string(name[2]) + "/" + name,
-	)
-	if err != nil {
-		panic(err)
-	}
-	return siaPath
-}
-
 // UploadFile generates a new file of configurable size at the given path and
-// uploads it to Sia
+// uploads it to Sia. The contents of the file are derived from the seed and the
+// file name. The file is registered with the upload tracker if one is passed.
+// The siapath of the uploaded file is returned
 func UploadFile(
 	sc *sia.Client,
+	tracker *UploadTracker,
+	layout SiaPathLayout,
 	dir string,
+	seed []byte,
 	dataPieces, parityPieces uint64,
 	size uint64,
-) (err error) {
+) (siaPath modules.SiaPath, err error) {
 	var name = hex.EncodeToString(fastrand.Bytes(16)) + ".dat"
 	var localPath = dir + "/" + name
 
 	file, err := os.Create(localPath)
 	if err != nil {
-		return err
+		return siaPath, err
 	}
 
-	_, err = io.CopyN(file, frand.Reader, int64(size))
+	var fileSeed = crypto.HashBytes(append(append([]byte{}, seed...), name...))
+	_, err = io.CopyN(file, frand.NewCustom(fileSeed[:], 1024, 12), int64(size))
 	file.Close()
 	if err != nil {
 		os.Remove(localPath) // Clean up on error
-		return err
+		return siaPath, err
 	}
 
 	// We have a file of `size` bytes at `path`. Now upload it to Sia
 
+	if siaPath, err = layout.SiaPath(localPath); err != nil {
+		os.Remove(localPath) // Clean up on error
+		return siaPath, err
+	}
 	if err = sc.RenterUploadPost(
 		dir+"/"+name,
-		newSiaPath(name),
+		siaPath,
 		dataPieces,
 		parityPieces,
 	); err != nil {
 		os.Remove(localPath) // Clean up on error
-		return err
+		return siaPath, err
 	}
 
+	if tracker != nil {
+		tracker.Submit(siaPath)
+	}
+
+	return siaPath, nil
+}
+
+// ResubmitUpload removes a stalled file from the renter and uploads it again
+// from its local copy
+func ResubmitUpload(
+	sc *sia.Client,
+	tracker *UploadTracker,
+	file modules.FileInfo,
+	dataPieces, parityPieces uint64,
+) (err error) {
+	if _, err = os.Stat(file.LocalPath); err != nil {
+		return fmt.Errorf("local copy of '%s' is not available: %s", file.SiaPath, err)
+	}
+	if err = sc.RenterDeletePost(file.SiaPath); err != nil {
+		return fmt.Errorf("error deleting '%s' from Sia: %s", file.SiaPath, err)
+	}
+	if err = sc.RenterUploadPost(
+		file.LocalPath,
+		file.SiaPath,
+		dataPieces,
+		parityPieces,
+	); err != nil {
+		return fmt.Errorf("error uploading '%s' to Sia: %s", file.SiaPath, err)
+	}
+
+	if tracker != nil {
+		tracker.resetStalled(file.SiaPath)
+	}
 	return nil
 }
 
 // FinishUploads looks through all the files in the uploads dir and removes the
-// ones which have finished uploading to Sia
-func FinishUploads(sc *sia.Client, uploadsDir string) error {
+// ones which have finished uploading to Sia. Files which are no longer on the
+// renter are removed as well. Only the files which were uploaded by this run
+// are touched, owned tells which siapaths those are. Errors don't stop the
+// other files from being checked, the first error is returned
+func FinishUploads(
+	sc *sia.Client,
+	layout SiaPathLayout,
+	uploadsDir string,
+	owned func(modules.SiaPath) bool,
+) (firstErr error) {
 	files, err := ioutil.ReadDir(uploadsDir)
 	if err != nil {
 		return err
 	}
+	var fail = func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
 
 	var sfile api.RenterFile
+	var siaPath modules.SiaPath
 	for _, file := range files {
-		if sfile, err = sc.RenterFileGet(newSiaPath(file.Name())); err != nil {
-			if err.Error() == "path does not exist" {
-				os.Remove(uploadsDir + "/" + file.Name())
+		var localPath = uploadsDir + "/" + file.Name()
+		if siaPath, err = layout.SiaPath(localPath); err != nil || !owned(siaPath) {
+			continue // Left over from another run, or not a benchmark file
+		}
+		if sfile, err = sc.RenterFileGet(siaPath); err != nil {
+			if strings.Contains(err.Error(), siafile.ErrUnknownPath.Error()) {
+				log.Debug("File '%s' is not on the renter, removing local copy", file.Name())
+				if err = os.Remove(localPath); err != nil {
+					fail(fmt.Errorf("error removing '%s': %s", localPath, err))
+				}
 				continue
 			}
 
-			return fmt.Errorf("error getting '%s' from Sia: %s", file.Name(), err)
+			fail(fmt.Errorf("error getting '%s' from Sia: %s", file.Name(), err))
+			continue
 		}
 
 		if sfile.File.UploadProgress >= 100 && sfile.File.MaxHealthPercent >= 100 {
 			log.Debug("File '%s' is done uploading, removing local copy", file.Name())
 			// Upload is done, remove source file
-			if err = os.Remove(uploadsDir + "/" + file.Name()); err != nil {
-				return fmt.Errorf("error removing '%s': %s", uploadsDir+"/"+file.Name(), err)
+			if err = os.Remove(localPath); err != nil {
+				fail(fmt.Errorf("error removing '%s': %s", localPath, err))
 			}
 		}
 	}
-	return nil
+	return firstErr
+}
+
+// BenchmarkSiaFiles returns all the files on the renter which were uploaded by
+// the benchmark tool. Files are recognized by their name and the directory
+// which contains the roots of all runs
+func BenchmarkSiaFiles(sc *sia.Client, prefix string) (siaPaths []modules.SiaPath, err error) {
+	files, err := sc.RenterFilesGet(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files.Files {
+		if IsBenchmarkSiaPath(file.SiaPath, prefix) {
+			siaPaths = append(siaPaths, file.SiaPath)
+		}
+	}
+	return siaPaths, nil
 }
@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"math"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+)
+
+// fillFileHealth calculates the lowest and average health and redundancy of
+// the files on the renter, and how many files there are in each redundancy
+// range. Files below a redundancy of 1 can't be recovered
+func fillFileHealth(metrics *Metrics, files []modules.FileInfo) {
+	if len(files) == 0 {
+		return
+	}
+
+	metrics.FileHealthMinPercent = math.Inf(1)
+	metrics.FileRedundancyMin = math.Inf(1)
+	var redundancyCount int
+	for _, file := range files {
+		metrics.FileHealthMinPercent = math.Min(metrics.FileHealthMinPercent, file.MaxHealthPercent)
+		metrics.FileHealthAvgPercent += file.MaxHealthPercent
+
+		// The redundancy is negative when the renter has not calculated it
+		// yet
+		if file.Redundancy < 0 {
+			continue
+		}
+		redundancyCount++
+		metrics.FileRedundancyMin = math.Min(metrics.FileRedundancyMin, file.Redundancy)
+		metrics.FileRedundancyAvg += file.Redundancy
+
+		switch {
+		case file.Redundancy < 1:
+			metrics.FileRedundancyBelow1Count++
+		case file.Redundancy < 2:
+			metrics.FileRedundancy1To2Count++
+		case file.Redundancy < 3:
+			metrics.FileRedundancy2To3Count++
+		default:
+			metrics.FileRedundancyAbove3Count++
+		}
+	}
+
+	metrics.FileHealthAvgPercent /= float64(len(files))
+	if redundancyCount > 0 {
+		metrics.FileRedundancyAvg /= float64(redundancyCount)
+	} else {
+		metrics.FileRedundancyMin = 0
+	}
+}
+
+// ExpectedRedundancy returns the redundancy of files uploaded with the given
+// erasure coding settings
+func ExpectedRedundancy(dataPieces, parityPieces uint64) float64 {
+	if dataPieces == 0 {
+		return 0
+	}
+	return float64(dataPieces+parityPieces) / float64(dataPieces)
+}
@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"encoding/csv"
+	"sort"
+	"strconv"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/node/api"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// HostMetrics contains the contract metrics of a single host. If the renter
+// has multiple contracts with the host (for example an active contract and the
+// refreshed contract it replaced) the metrics are summed
+type HostMetrics struct {
+	Timestamp     time.Time `csv:"timestamp"`
+	HostPublicKey string    `csv:"host_public_key"`
+	NetAddress    string    `csv:"net_address"`
+	HostVersion   string    `csv:"host_version"`
+
+	// State of the most relevant contract with the host. Active contracts take
+	// precedence over passive, disabled and refreshed contracts
+	State         string `csv:"state"`
+	ContractCount int    `csv:"contract_count"`
+	GoodForUpload bool   `csv:"good_for_upload"`
+	GoodForRenew  bool   `csv:"good_for_renew"`
+
+	// Size of the data stored on the host, and how much it changed since the
+	// previous round
+	Size      uint64 `csv:"size"`
+	SizeDelta int64  `csv:"size_delta"`
+
+	FundsRemaining   types.Currency `csv:"funds_remaining"`
+	TotalCost        types.Currency `csv:"total_cost"`
+	StorageSpending  types.Currency `csv:"storage_spending"`
+	UploadSpending   types.Currency `csv:"upload_spending"`
+	DownloadSpending types.Currency `csv:"download_spending"`
+	FeeSpending      types.Currency `csv:"fee_spending"`
+}
+
+// HostCollector collects contract metrics per host. It remembers the contract
+// sizes of the previous round so it can calculate how much data was uploaded
+// to every host
+type HostCollector struct {
+	lastSize map[string]uint64
+}
+
+// NewHostCollector creates a new per host metrics collector
+func NewHostCollector() *HostCollector {
+	return &HostCollector{lastSize: make(map[string]uint64)}
+}
+
+// Collect groups the contracts of the renter by host. Expired contracts are
+// not included. The returned metrics are sorted by host public key
+func (hc *HostCollector) Collect(contracts api.RenterContracts, now time.Time) (hosts []HostMetrics) {
+	var byHost = make(map[string]*HostMetrics)
+	var add = func(contract api.RenterContract, state string, countSize bool) {
+		var key = contract.HostPublicKey.String()
+		host, ok := byHost[key]
+		if !ok {
+			host = &HostMetrics{
+				Timestamp:     now,
+				HostPublicKey: key,
+				NetAddress:    string(contract.NetAddress),
+				HostVersion:   contract.HostVersion,
+				State:         state,
+				GoodForUpload: contract.GoodForUpload,
+				GoodForRenew:  contract.GoodForRenew,
+			}
+			byHost[key] = host
+		}
+		if countSize {
+			host.Size += contract.Size
+		}
+		host.ContractCount++
+		host.FundsRemaining = host.FundsRemaining.Add(contract.RenterFunds)
+		host.TotalCost = host.TotalCost.Add(contract.TotalCost)
+		host.StorageSpending = host.StorageSpending.Add(contract.StorageSpending)
+		host.UploadSpending = host.UploadSpending.Add(contract.UploadSpending)
+		host.DownloadSpending = host.DownloadSpending.Add(contract.DownloadSpending)
+		host.FeeSpending = host.FeeSpending.Add(contract.Fees)
+	}
+
+	// The order of these loops determines which contract state is reported for
+	// the host
+	for _, contract := range contracts.ActiveContracts {
+		add(contract, "active", true)
+	}
+	for _, contract := range contracts.PassiveContracts {
+		add(contract, "passive", true)
+	}
+	for _, contract := range contracts.DisabledContracts {
+		add(contract, "disabled", true)
+	}
+	for _, contract := range contracts.RefreshedContracts {
+		add(contract, "refreshed", false)
+	}
+
+	var lastSize = make(map[string]uint64, len(byHost))
+	for key, host := range byHost {
+		if prev, ok := hc.lastSize[key]; ok {
+			host.SizeDelta = int64(host.Size) - int64(prev)
+		}
+		lastSize[key] = host.Size
+		hosts = append(hosts, *host)
+	}
+	hc.lastSize = lastSize
+
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].HostPublicKey < hosts[j].HostPublicKey
+	})
+	return hosts
+}
+
+// HostMetricsHeaders returns all the CSV headers of the HostMetrics struct
+func HostMetricsHeaders() (headers []string) {
+	return csvHeaders(HostMetrics{})
+}
+
+// Values marshals all the values to a string and returns them in an array so
+// they can be written to the CSV
+func (h HostMetrics) Values() (values []string) {
+	return append(values,
+		h.Timestamp.UTC().Format("2006-01-02T15:04:05Z"),
+		h.HostPublicKey,
+		h.NetAddress,
+		h.HostVersion,
+
+		h.State,
+		strconv.Itoa(h.ContractCount),
+		strconv.FormatBool(h.GoodForUpload),
+		strconv.FormatBool(h.GoodForRenew),
+
+		strconv.FormatUint(h.Size, 10),
+		strconv.FormatInt(h.SizeDelta, 10),
+
+		h.FundsRemaining.String(),
+		h.TotalCost.String(),
+		h.StorageSpending.String(),
+		h.UploadSpending.String(),
+		h.DownloadSpending.String(),
+		h.FeeSpending.String(),
+	)
+}
+
+// WriteCSV adds a row to an existing CSV file with the stored values of
+// HostMetrics
+func (h HostMetrics) WriteCSV(f *csv.Writer) error {
+	defer f.Flush()
+	return f.Write(h.Values())
+}
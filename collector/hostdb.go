@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"encoding/csv"
+	"strconv"
+	"time"
+
+	"github.com/Fornaxian/log"
+	sia "gitlab.com/NebulousLabs/Sia/node/api/client"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// HostSnapshot contains the renter's view of a single host in the host DB at
+// the time of the snapshot
+type HostSnapshot struct {
+	Timestamp time.Time `csv:"timestamp"`
+
+	// Label describes when the snapshot was taken: start, interval or exit
+	Label string `csv:"snapshot"`
+
+	PublicKey          string `csv:"public_key"`
+	NetAddress         string `csv:"net_address"`
+	Version            string `csv:"version"`
+	AcceptingContracts bool   `csv:"accepting_contracts"`
+	Filtered           bool   `csv:"filtered"`
+
+	RemainingStorage uint64            `csv:"remaining_storage"`
+	TotalStorage     uint64            `csv:"total_storage"`
+	MaxDuration      types.BlockHeight `csv:"max_duration"`
+
+	ContractPrice          types.Currency `csv:"contract_price"`
+	StoragePrice           types.Currency `csv:"storage_price"`
+	UploadBandwidthPrice   types.Currency `csv:"upload_bandwidth_price"`
+	DownloadBandwidthPrice types.Currency `csv:"download_bandwidth_price"`
+	Collateral             types.Currency `csv:"collateral"`
+
+	// Fraction of the time the host was online, historic and in the recent
+	// scans
+	HistoricUptime float64 `csv:"historic_uptime"`
+	RecentUptime   float64 `csv:"recent_uptime"`
+
+	Score types.Currency `csv:"score"`
+}
+
+// CollectHostDB takes a snapshot of all the active hosts in the renter's host
+// DB. The score of every host is requested separately, if that fails the score
+// is left empty
+func CollectHostDB(sc *sia.Client, label string) (hosts []HostSnapshot, err error) {
+	var now = time.Now()
+
+	active, err := sc.HostDbActiveGet()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range active.Hosts {
+		var host = HostSnapshot{
+			Timestamp:              now,
+			Label:                  label,
+			PublicKey:              entry.PublicKeyString,
+			NetAddress:             string(entry.NetAddress),
+			Version:                entry.Version,
+			AcceptingContracts:     entry.AcceptingContracts,
+			Filtered:               entry.Filtered,
+			RemainingStorage:       entry.RemainingStorage,
+			TotalStorage:           entry.TotalStorage,
+			MaxDuration:            entry.MaxDuration,
+			ContractPrice:          entry.ContractPrice,
+			StoragePrice:           entry.StoragePrice,
+			UploadBandwidthPrice:   entry.UploadBandwidthPrice,
+			DownloadBandwidthPrice: entry.DownloadBandwidthPrice,
+			Collateral:             entry.Collateral,
+		}
+
+		if total := entry.HistoricUptime + entry.HistoricDowntime; total > 0 {
+			host.HistoricUptime = float64(entry.HistoricUptime) / float64(total)
+		}
+		if len(entry.ScanHistory) > 0 {
+			var success int
+			for _, scan := range entry.ScanHistory {
+				if scan.Success {
+					success++
+				}
+			}
+			host.RecentUptime = float64(success) / float64(len(entry.ScanHistory))
+		}
+
+		details, err := sc.HostDbHostsGet(entry.PublicKey)
+		if err != nil {
+			log.Debug("Could not get score of host %s: %s", host.PublicKey, err)
+		} else {
+			host.Score = details.ScoreBreakdown.Score
+		}
+
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// HostSnapshotHeaders returns all the CSV headers of the HostSnapshot struct
+func HostSnapshotHeaders() (headers []string) {
+	return csvHeaders(HostSnapshot{})
+}
+
+// Values marshals all the values to a string and returns them in an array so
+// they can be written to the CSV
+func (h HostSnapshot) Values() (values []string) {
+	return append(values,
+		h.Timestamp.UTC().Format("2006-01-02T15:04:05Z"),
+		h.Label,
+
+		h.PublicKey,
+		h.NetAddress,
+		h.Version,
+		strconv.FormatBool(h.AcceptingContracts),
+		strconv.FormatBool(h.Filtered),
+
+		strconv.FormatUint(h.RemainingStorage, 10),
+		strconv.FormatUint(h.TotalStorage, 10),
+		strconv.FormatUint(uint64(h.MaxDuration), 10),
+
+		h.ContractPrice.String(),
+		h.StoragePrice.String(),
+		h.UploadBandwidthPrice.String(),
+		h.DownloadBandwidthPrice.String(),
+		h.Collateral.String(),
+
+		strconv.FormatFloat(h.HistoricUptime, 'f', 4, 64),
+		strconv.FormatFloat(h.RecentUptime, 'f', 4, 64),
+
+		h.Score.String(),
+	)
+}
+
+// WriteCSV adds a row to an existing CSV file with the stored values of
+// HostSnapshot
+func (h HostSnapshot) WriteCSV(f *csv.Writer) error {
+	defer f.Flush()
+	return f.Write(h.Values())
+}
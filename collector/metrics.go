@@ -18,8 +18,34 @@ type Metrics struct {
 	FileCount                  uint64 `csv:"file_count"`
 	FileTotalBytes             uint64 `csv:"file_total_bytes"`
 	FileUploadsInProgressCount uint64 `csv:"file_uploads_in_progress_count"`
+	FileUploadsStalledCount    uint64 `csv:"file_uploads_stalled_count"`
+	FileUploadsFailedCount     uint64 `csv:"file_uploads_failed_count"` // Since the start of the test
 	FileUploadedBytes          uint64 `csv:"file_uploaded_bytes"`
 
+	// Health and redundancy of all files on the renter, see fillFileHealth
+	FileHealthMinPercent      float64 `csv:"file_health_min_percent"`
+	FileHealthAvgPercent      float64 `csv:"file_health_avg_percent"`
+	FileRedundancyMin         float64 `csv:"file_redundancy_min"`
+	FileRedundancyAvg         float64 `csv:"file_redundancy_avg"`
+	FileRedundancyBelow1Count uint64  `csv:"file_redundancy_below_1_count"`
+	FileRedundancy1To2Count   uint64  `csv:"file_redundancy_1_to_2_count"`
+	FileRedundancy2To3Count   uint64  `csv:"file_redundancy_2_to_3_count"`
+	FileRedundancyAbove3Count uint64  `csv:"file_redundancy_above_3_count"`
+
+	// Free space on the filesystem of the upload queue, and whether it's below
+	// the configured reserve so no new files can be generated
+	UploadsDirFreeBytes uint64 `csv:"uploads_dir_free_bytes"`
+	UploadsDirSpaceLow  bool   `csv:"uploads_dir_space_low"`
+
+	UploadTimeP50 time.Duration `csv:"upload_time_p50"`
+	UploadTimeP90 time.Duration `csv:"upload_time_p90"`
+	UploadTimeP99 time.Duration `csv:"upload_time_p99"`
+	UploadTimeMax time.Duration `csv:"upload_time_max"`
+	HealthTimeP50 time.Duration `csv:"health_time_p50"`
+	HealthTimeP90 time.Duration `csv:"health_time_p90"`
+	HealthTimeP99 time.Duration `csv:"health_time_p99"`
+	HealthTimeMax time.Duration `csv:"health_time_max"`
+
 	ContractCountTotal            int `csv:"contract_count_total"`
 	ContractCountActive           int `csv:"contract_count_active"`
 	ContractCountPassive          int `csv:"contract_count_passive"`
@@ -36,6 +62,12 @@ type Metrics struct {
 	ContractSizeExpired          uint64 `csv:"contract_size_expired"`
 	ContractSizeExpiredRefreshed uint64 `csv:"contract_size_expired_refreshed"`
 
+	// Total file size divided by total contract size, and the redundancy of
+	// the erasure coding settings of the uploads. The efficiency should get
+	// close to 1 / ExpectedRedundancy
+	Efficiency         float64 `csv:"efficiency"`
+	ExpectedRedundancy float64 `csv:"expected_redundancy"`
+
 	ContractFundsRemainingTotal            types.Currency `csv:"contract_funds_remaining_total"`
 	ContractFundsRemainingActive           types.Currency `csv:"contract_funds_remaining_active"`
 	ContractFundsRemainingPassive          types.Currency `csv:"contract_funds_remaining_passive"`
@@ -82,19 +114,54 @@ type Metrics struct {
 	WalletOutgoingSiacoins types.Currency `csv:"wallet_outgoing_siacoins"`
 	WalletIncomingSiacoins types.Currency `csv:"wallet_incoming_siacoins"`
 
-	RenterAllowance        types.Currency `csv:"renter_allowance"`
-	RenterContractFees     types.Currency `csv:"renter_contract_fees"`
-	RenterTotalAllocated   types.Currency `csv:"renter_total_allocated"`
-	RenterDownloadSpending types.Currency `csv:"renter_download_spending"`
-	RenterStorageSpending  types.Currency `csv:"renter_storage_spending"`
-	RenterUploadSpending   types.Currency `csv:"renter_upload_spending"`
-	RenterUnspent          types.Currency `csv:"renter_unspent"`
+	RenterAllowance        types.Currency    `csv:"renter_allowance"`
+	RenterAllowancePeriod  types.BlockHeight `csv:"renter_allowance_period"`
+	RenterContractFees     types.Currency    `csv:"renter_contract_fees"`
+	RenterTotalAllocated   types.Currency    `csv:"renter_total_allocated"`
+	RenterDownloadSpending types.Currency    `csv:"renter_download_spending"`
+	RenterStorageSpending  types.Currency    `csv:"renter_storage_spending"`
+	RenterUploadSpending   types.Currency    `csv:"renter_upload_spending"`
+	RenterUnspent          types.Currency    `csv:"renter_unspent"`
+
+	// Costs derived from the spending metrics, see CalculateCosts
+	CostUploadPerTB        types.Currency `csv:"cost_upload_per_tb"`
+	CostStoragePerTBMonth  types.Currency `csv:"cost_storage_per_tb_month"`
+	CostFeeOverheadPercent float64        `csv:"cost_fee_overhead_percent"`
+	CostProjectedTotal     types.Currency `csv:"cost_projected_total"`
+
+	// Projection of reaching the success size threshold, see
+	// CalculateProjection
+	ProjectedTimeRemaining time.Duration `csv:"projected_time_remaining"`
+	ProjectedAllowance     string        `csv:"projected_allowance"`
+
+	// Chain metrics are only collected if enabled
+	ConsensusHeight  types.BlockHeight `csv:"consensus_height"`
+	ConsensusSynced  bool              `csv:"consensus_synced"`
+	GatewayPeerCount int               `csv:"gateway_peer_count"`
+	TpoolFeeMinimum  types.Currency    `csv:"tpool_fee_minimum"`
+	TpoolFeeMaximum  types.Currency    `csv:"tpool_fee_maximum"`
+
+	// Resource usage of the siad process, only collected if enabled
+	ProcessPID            int           `csv:"process_pid"`
+	ProcessCPUTime        time.Duration `csv:"process_cpu_time"`
+	ProcessRSS            uint64        `csv:"process_rss"`
+	ProcessOpenFiles      uint64        `csv:"process_open_files"`
+	ProcessThreads        uint64        `csv:"process_threads"`
+	ProcessDiskReadBytes  uint64        `csv:"process_disk_read_bytes"`
+	ProcessDiskWriteBytes uint64        `csv:"process_disk_write_bytes"`
+	ProcessNetRxBytes     uint64        `csv:"process_net_rx_bytes"`
+	ProcessNetTxBytes     uint64        `csv:"process_net_tx_bytes"`
 }
 
 // MetricsHeaders returns all the CSV headers of the Metrics struct so they can
 // be written at the beginning of a new CSV file
 func MetricsHeaders() (headers []string) {
-	t := reflect.TypeOf(Metrics{})
+	return csvHeaders(Metrics{})
+}
+
+// csvHeaders returns the csv tags of all the fields in a struct
+func csvHeaders(v interface{}) (headers []string) {
+	t := reflect.TypeOf(v)
 
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
@@ -114,8 +181,31 @@ func (m Metrics) Values() (values []string) {
 		strconv.FormatUint(m.FileCount, 10),
 		strconv.FormatUint(m.FileTotalBytes, 10),
 		strconv.FormatUint(m.FileUploadsInProgressCount, 10),
+		strconv.FormatUint(m.FileUploadsStalledCount, 10),
+		strconv.FormatUint(m.FileUploadsFailedCount, 10),
 		strconv.FormatUint(m.FileUploadedBytes, 10),
 
+		strconv.FormatFloat(m.FileHealthMinPercent, 'f', 2, 64),
+		strconv.FormatFloat(m.FileHealthAvgPercent, 'f', 2, 64),
+		strconv.FormatFloat(m.FileRedundancyMin, 'f', 2, 64),
+		strconv.FormatFloat(m.FileRedundancyAvg, 'f', 2, 64),
+		strconv.FormatUint(m.FileRedundancyBelow1Count, 10),
+		strconv.FormatUint(m.FileRedundancy1To2Count, 10),
+		strconv.FormatUint(m.FileRedundancy2To3Count, 10),
+		strconv.FormatUint(m.FileRedundancyAbove3Count, 10),
+
+		strconv.FormatUint(m.UploadsDirFreeBytes, 10),
+		strconv.FormatBool(m.UploadsDirSpaceLow),
+
+		m.UploadTimeP50.String(),
+		m.UploadTimeP90.String(),
+		m.UploadTimeP99.String(),
+		m.UploadTimeMax.String(),
+		m.HealthTimeP50.String(),
+		m.HealthTimeP90.String(),
+		m.HealthTimeP99.String(),
+		m.HealthTimeMax.String(),
+
 		strconv.Itoa(m.ContractCountTotal),
 		strconv.Itoa(m.ContractCountActive),
 		strconv.Itoa(m.ContractCountPassive),
@@ -132,6 +222,9 @@ func (m Metrics) Values() (values []string) {
 		strconv.FormatUint(m.ContractSizeExpired, 10),
 		strconv.FormatUint(m.ContractSizeExpiredRefreshed, 10),
 
+		strconv.FormatFloat(m.Efficiency, 'f', 4, 64),
+		strconv.FormatFloat(m.ExpectedRedundancy, 'f', 2, 64),
+
 		m.ContractFundsRemainingTotal.String(),
 		m.ContractFundsRemainingActive.String(),
 		m.ContractFundsRemainingPassive.String(),
@@ -179,12 +272,37 @@ func (m Metrics) Values() (values []string) {
 		m.WalletIncomingSiacoins.String(),
 
 		m.RenterAllowance.String(),
+		strconv.FormatUint(uint64(m.RenterAllowancePeriod), 10),
 		m.RenterContractFees.String(),
 		m.RenterTotalAllocated.String(),
 		m.RenterDownloadSpending.String(),
 		m.RenterStorageSpending.String(),
 		m.RenterUploadSpending.String(),
 		m.RenterUnspent.String(),
+
+		m.CostUploadPerTB.String(),
+		m.CostStoragePerTBMonth.String(),
+		strconv.FormatFloat(m.CostFeeOverheadPercent, 'f', 4, 64),
+		m.CostProjectedTotal.String(),
+
+		m.ProjectedTimeRemaining.String(),
+		m.ProjectedAllowance,
+
+		strconv.FormatUint(uint64(m.ConsensusHeight), 10),
+		strconv.FormatBool(m.ConsensusSynced),
+		strconv.Itoa(m.GatewayPeerCount),
+		m.TpoolFeeMinimum.String(),
+		m.TpoolFeeMaximum.String(),
+
+		strconv.Itoa(m.ProcessPID),
+		m.ProcessCPUTime.String(),
+		strconv.FormatUint(m.ProcessRSS, 10),
+		strconv.FormatUint(m.ProcessOpenFiles, 10),
+		strconv.FormatUint(m.ProcessThreads, 10),
+		strconv.FormatUint(m.ProcessDiskReadBytes, 10),
+		strconv.FormatUint(m.ProcessDiskWriteBytes, 10),
+		strconv.FormatUint(m.ProcessNetRxBytes, 10),
+		strconv.FormatUint(m.ProcessNetTxBytes, 10),
 	)
 }
 
@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fornaxian/log"
+)
+
+// The kernel reports CPU times in clock ticks. USER_HZ is 100 on practically
+// all Linux systems
+const clockTicks = 100
+
+// ProcessCollector samples the resource usage of the siad process from the
+// /proc filesystem. This only works on Linux
+type ProcessCollector struct {
+	pid      int
+	discover bool
+
+	// Files which could not be read, so the error is only logged once
+	unreadable map[string]bool
+}
+
+// NewProcessCollector creates a new process collector for the given pid. If the
+// pid is 0 the collector looks for a process called siad, and looks again if
+// that process disappears
+func NewProcessCollector(pid int) *ProcessCollector {
+	return &ProcessCollector{pid: pid, discover: pid == 0, unreadable: make(map[string]bool)}
+}
+
+// Collect reads the CPU time, memory usage, open file descriptors, thread
+// count and I/O counters of the siad process and adds them to the metrics.
+//
+// The network counters are read from /proc/<pid>/net/dev, which contains the
+// traffic of all interfaces in the process' network namespace except loopback
+func (pc *ProcessCollector) Collect(metrics *Metrics) (err error) {
+	if pc.discover {
+		if _, err = os.Stat(fmt.Sprintf("/proc/%d", pc.pid)); pc.pid == 0 || err != nil {
+			if pc.pid, err = findProcess("siad"); err != nil {
+				return err
+			}
+		}
+	}
+
+	var dir = fmt.Sprintf("/proc/%d", pc.pid)
+	metrics.ProcessPID = pc.pid
+
+	// Parse the stat file. The second field is the process name, which can
+	// contain spaces, so we start parsing after the closing parenthesis
+	stat, err := ioutil.ReadFile(dir + "/stat")
+	if err != nil {
+		return err
+	}
+	var statFields = strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	if len(statFields) < 22 {
+		return fmt.Errorf("unexpected format of %s/stat", dir)
+	}
+	// Field numbers in proc(5) start at 1 and we skipped the first two
+	var statField = func(n int) uint64 {
+		v, _ := strconv.ParseUint(statFields[n-3], 10, 64)
+		return v
+	}
+	metrics.ProcessCPUTime = time.Duration(statField(14)+statField(15)) * time.Second / clockTicks
+	metrics.ProcessThreads = statField(20)
+	metrics.ProcessRSS = statField(24) * uint64(os.Getpagesize())
+
+	// Reading the file descriptors and I/O counters requires the same
+	// permissions as ptrace, so this fails if siad runs as a different user.
+	// These metrics are skipped in that case
+	fds, err := ioutil.ReadDir(dir + "/fd")
+	if pc.optional("fd", err) {
+		metrics.ProcessOpenFiles = uint64(len(fds))
+	}
+	err = readKeyValues(dir+"/io", func(key string, value uint64) {
+		switch key {
+		case "read_bytes":
+			metrics.ProcessDiskReadBytes = value
+		case "write_bytes":
+			metrics.ProcessDiskWriteBytes = value
+		}
+	})
+	pc.optional("io", err)
+
+	return readNetDev(dir+"/net/dev", metrics)
+}
+
+// optional returns whether a file of the process could be read. The first
+// error for every file is logged
+func (pc *ProcessCollector) optional(file string, err error) bool {
+	if err == nil {
+		return true
+	}
+	if !pc.unreadable[file] {
+		pc.unreadable[file] = true
+		log.Debug("Can't read %s of the siad process, skipping these metrics: %s", file, err)
+	}
+	return false
+}
+
+// findProcess returns the pid of the first process with the given name
+func findProcess(name string) (pid int, err error) {
+	dirs, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return 0, err
+	}
+	for _, dir := range dirs {
+		if pid, err = strconv.Atoi(dir.Name()); err != nil {
+			continue
+		}
+		comm, err := ioutil.ReadFile("/proc/" + dir.Name() + "/comm")
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(comm)) == name {
+			return pid, nil
+		}
+	}
+	return 0, fmt.Errorf("no process called '%s' found", name)
+}
+
+// readKeyValues parses a file with lines in the format 'key: value'
+func readKeyValues(path string, fn func(key string, value uint64)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var scanner = bufio.NewScanner(f)
+	for scanner.Scan() {
+		var parts = strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		fn(parts[0], value)
+	}
+	return scanner.Err()
+}
+
+// readNetDev sums the received and transmitted bytes of all network interfaces
+// except loopback
+func readNetDev(path string, metrics *Metrics) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	metrics.ProcessNetRxBytes, metrics.ProcessNetTxBytes = 0, 0
+
+	var scanner = bufio.NewScanner(f)
+	for scanner.Scan() {
+		var parts = strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "lo" {
+			continue // Header lines and loopback interface
+		}
+		var fields = strings.Fields(parts[1])
+		if len(fields) < 9 {
+			continue
+		}
+		rx, _ := strconv.ParseUint(fields[0], 10, 64)
+		tx, _ := strconv.ParseUint(fields[8], 10, 64)
+		metrics.ProcessNetRxBytes += rx
+		metrics.ProcessNetTxBytes += tx
+	}
+	return scanner.Err()
+}
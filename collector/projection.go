@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"math"
+	"time"
+)
+
+// Whether the allowance covers the projected total cost, see
+// CalculateProjection
+const (
+	AllowanceEnough  = "enough"
+	AllowanceTooLow  = "too_low"
+	AllowanceUnknown = "unknown"
+)
+
+// CalculateProjection projects how long it will take for the total file size
+// to reach the success size threshold at the average upload speed, and whether
+// the allowance is enough to pay for the projected total cost. The speed is in
+// bytes of contract data per second, so it's scaled by the current redundancy
+// efficiency to get the speed of the file data.
+//
+// ProjectedTimeRemaining is 0 if the threshold is disabled or reached, or if
+// nothing is being uploaded. The projected cost is unknown if the threshold is
+// disabled or nothing has been uploaded yet. CalculateCosts needs to be called
+// first
+func CalculateProjection(metrics *Metrics, bwAverage uint64, successSizeThreshold uint64) {
+	metrics.ProjectedTimeRemaining = 0
+	if successSizeThreshold == 0 || metrics.FileTotalBytes == 0 {
+		metrics.ProjectedAllowance = AllowanceUnknown
+	} else if metrics.CostProjectedTotal.Cmp(metrics.RenterAllowance) <= 0 {
+		metrics.ProjectedAllowance = AllowanceEnough
+	} else {
+		metrics.ProjectedAllowance = AllowanceTooLow
+	}
+
+	if successSizeThreshold == 0 || metrics.FileTotalBytes >= successSizeThreshold {
+		return
+	}
+
+	var fileSpeed = float64(bwAverage) * metrics.Efficiency
+	if fileSpeed <= 0 {
+		return
+	}
+
+	// Durations can't be longer than about 290 years. The limit is compared in
+	// seconds, a float64 can't hold the largest duration exactly
+	var seconds = float64(successSizeThreshold-metrics.FileTotalBytes) / fileSpeed
+	if seconds >= math.MaxInt64/1e9 {
+		metrics.ProjectedTimeRemaining = math.MaxInt64
+	} else {
+		metrics.ProjectedTimeRemaining = time.Duration(seconds * 1e9)
+	}
+}
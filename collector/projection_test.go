@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+func TestCalculateProjection(t *testing.T) {
+	var sc = types.SiacoinPrecision
+	var tests = []struct {
+		name      string
+		metrics   Metrics
+		bwAverage uint64
+		threshold uint64
+
+		remaining time.Duration
+		allowance string
+	}{{
+		name:      "threshold disabled",
+		metrics:   Metrics{FileTotalBytes: 1e9, Efficiency: 0.5, RenterAllowance: sc},
+		bwAverage: 1e6,
+		allowance: AllowanceUnknown,
+	}, {
+		name:      "nothing uploaded yet",
+		metrics:   Metrics{RenterAllowance: sc},
+		bwAverage: 1e6,
+		threshold: 1e9,
+		allowance: AllowanceUnknown,
+	}, {
+		name: "threshold reached",
+		metrics: Metrics{
+			FileTotalBytes: 2e9, Efficiency: 0.5,
+			CostProjectedTotal: sc, RenterAllowance: sc,
+		},
+		bwAverage: 1e6,
+		threshold: 1e9,
+		allowance: AllowanceEnough,
+	}, {
+		name: "uploading",
+		metrics: Metrics{
+			FileTotalBytes: 1e9, Efficiency: 0.5,
+			CostProjectedTotal: sc, RenterAllowance: sc.Mul64(2),
+		},
+		bwAverage: 1e6, // 500 KB of file data per second
+		threshold: 2e9,
+		remaining: 2000 * time.Second,
+		allowance: AllowanceEnough,
+	}, {
+		name: "allowance too low",
+		metrics: Metrics{
+			FileTotalBytes: 1e9, Efficiency: 0.5,
+			CostProjectedTotal: sc.Mul64(2), RenterAllowance: sc,
+		},
+		bwAverage: 1e6,
+		threshold: 2e9,
+		remaining: 2000 * time.Second,
+		allowance: AllowanceTooLow,
+	}, {
+		name: "not uploading",
+		metrics: Metrics{
+			FileTotalBytes: 1e9, Efficiency: 0.5,
+			CostProjectedTotal: sc, RenterAllowance: sc,
+		},
+		threshold: 2e9,
+		allowance: AllowanceEnough,
+	}, {
+		name: "no contract data",
+		metrics: Metrics{
+			FileTotalBytes:     1e9,
+			CostProjectedTotal: sc, RenterAllowance: sc,
+		},
+		bwAverage: 1e6,
+		threshold: 2e9,
+		allowance: AllowanceEnough,
+	}, {
+		name: "longer than a duration can be",
+		metrics: Metrics{
+			FileTotalBytes: 1, Efficiency: 1,
+			CostProjectedTotal: sc, RenterAllowance: sc,
+		},
+		bwAverage: 1,
+		threshold: math.MaxUint64,
+		remaining: math.MaxInt64,
+		allowance: AllowanceEnough,
+	}}
+
+	for _, test := range tests {
+		// Results of an earlier round are overwritten
+		var metrics = test.metrics
+		metrics.ProjectedTimeRemaining = time.Hour
+		metrics.ProjectedAllowance = AllowanceTooLow
+
+		CalculateProjection(&metrics, test.bwAverage, test.threshold)
+		if metrics.ProjectedTimeRemaining != test.remaining {
+			t.Errorf("%s: remaining time is %s, want %s",
+				test.name, metrics.ProjectedTimeRemaining, test.remaining)
+		}
+		if metrics.ProjectedAllowance != test.allowance {
+			t.Errorf("%s: allowance is %s, want %s",
+				test.name, metrics.ProjectedAllowance, test.allowance)
+		}
+	}
+}
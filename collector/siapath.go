@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+)
+
+// Layouts for placing benchmark files on the renter
+const (
+	// LayoutFlat puts all files directly in the root directory
+	LayoutFlat = "flat"
+
+	// LayoutHashed spreads the files over subdirectories named after the
+	// first characters of the file name, two characters per level
+	LayoutHashed = "hashed"
+
+	// LayoutMirror mirrors the absolute path of the local file below the root
+	// directory
+	LayoutMirror = "mirror"
+)
+
+// benchmarkFileName matches the names of the files generated by the benchmark
+var benchmarkFileName = regexp.MustCompile(`^[0-9a-f]{32}\.dat$`)
+
+// legacySiaPath matches the siapaths of files uploaded by older versions of
+// the benchmark tool, which placed all files at the root of the renter
+var legacySiaPath = regexp.MustCompile(`^[0-9a-f]{2}/[0-9a-f]/[0-9a-f]{32}\.dat$`)
+
+// SiaPathLayout decides where the files uploaded by the benchmark are placed
+// on the renter
+type SiaPathLayout struct {
+	// Directory on the renter which contains all the files of the run
+	Root string
+
+	// One of LayoutFlat, LayoutHashed or LayoutMirror
+	Layout string
+
+	// Number of directory levels in the hashed layout
+	Depth int
+}
+
+// Validate checks if the layout can produce valid siapaths
+func (l SiaPathLayout) Validate() error {
+	if l.Root != "" {
+		if _, err := modules.NewSiaPath(l.Root); err != nil {
+			return fmt.Errorf("invalid root '%s': %s", l.Root, err)
+		}
+	}
+	switch l.Layout {
+	case LayoutFlat, LayoutMirror:
+	case LayoutHashed:
+		// The name has 32 hex characters, two are used for every level
+		if l.Depth < 1 || l.Depth > 16 {
+			return fmt.Errorf("depth must be between 1 and 16, not %d", l.Depth)
+		}
+	default:
+		return fmt.Errorf(
+			"layout must be \"%s\", \"%s\" or \"%s\", not \"%s\"",
+			LayoutFlat, LayoutHashed, LayoutMirror, l.Layout)
+	}
+	return nil
+}
+
+// SiaPath returns the siapath for a local file
+func (l SiaPathLayout) SiaPath(localPath string) (siaPath modules.SiaPath, err error) {
+	var name = filepath.Base(localPath)
+	var dirs []string
+	if l.Root != "" {
+		dirs = append(dirs, strings.Trim(l.Root, "/"))
+	}
+
+	switch l.Layout {
+	case LayoutHashed:
+		for i := 0; i < l.Depth && (i+1)*2 <= len(name); i++ {
+			dirs = append(dirs, name[i*2:(i+1)*2])
+		}
+	case LayoutMirror:
+		abs, err := filepath.Abs(filepath.Dir(localPath))
+		if err != nil {
+			return siaPath, err
+		}
+		if abs = strings.Trim(filepath.ToSlash(abs), "/"); abs != "" {
+			dirs = append(dirs, abs)
+		}
+	}
+
+	return modules.NewSiaPath(strings.Join(append(dirs, name), "/"))
+}
+
+// IsBenchmarkSiaPath checks if a siapath belongs to a file uploaded by the
+// benchmark tool. The prefix is the directory which contains the roots of all
+// runs. Files uploaded by older versions of the tool are also recognized. An
+// empty prefix only matches those, because any file on the renter could have
+// a name like a benchmark file
+func IsBenchmarkSiaPath(siaPath modules.SiaPath, prefix string) bool {
+	var path = siaPath.String()
+	if legacySiaPath.MatchString(path) {
+		return true
+	}
+	if prefix = strings.Trim(prefix, "/"); prefix == "" || !strings.HasPrefix(path, prefix+"/") {
+		return false
+	}
+	return benchmarkFileName.MatchString(filepath.Base(path))
+}
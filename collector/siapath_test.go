@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+)
+
+const testFileName = "0123456789abcdef0123456789abcdef.dat"
+
+func TestSiaPathLayoutValidate(t *testing.T) {
+	var tests = []struct {
+		name   string
+		layout SiaPathLayout
+		valid  bool
+	}{
+		{"flat", SiaPathLayout{Root: "benchmark/run", Layout: LayoutFlat}, true},
+		{"mirror", SiaPathLayout{Root: "benchmark/run", Layout: LayoutMirror}, true},
+		{"hashed", SiaPathLayout{Root: "benchmark/run", Layout: LayoutHashed, Depth: 2}, true},
+		{"hashed at the maximum depth", SiaPathLayout{Layout: LayoutHashed, Depth: 16}, true},
+		{"empty root", SiaPathLayout{Layout: LayoutFlat}, true},
+		{"hashed without depth", SiaPathLayout{Layout: LayoutHashed}, false},
+		{"hashed too deep", SiaPathLayout{Layout: LayoutHashed, Depth: 17}, false},
+		{"unknown layout", SiaPathLayout{Layout: "tree"}, false},
+		{"invalid root", SiaPathLayout{Root: "benchmark/../run", Layout: LayoutFlat}, false},
+	}
+	for _, test := range tests {
+		if err := test.layout.Validate(); (err == nil) != test.valid {
+			t.Errorf("%s: got error %v, want valid %t", test.name, err, test.valid)
+		}
+	}
+}
+
+func TestSiaPathLayoutSiaPath(t *testing.T) {
+	var dir, err = filepath.Abs("queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mirrored = strings.Trim(filepath.ToSlash(dir), "/")
+
+	var tests = []struct {
+		name   string
+		layout SiaPathLayout
+		want   string
+	}{
+		{"flat", SiaPathLayout{Root: "benchmark/run", Layout: LayoutFlat},
+			"benchmark/run/" + testFileName},
+		{"flat without root", SiaPathLayout{Layout: LayoutFlat},
+			testFileName},
+		{"root with slashes", SiaPathLayout{Root: "/benchmark/run/", Layout: LayoutFlat},
+			"benchmark/run/" + testFileName},
+		{"hashed", SiaPathLayout{Root: "benchmark/run", Layout: LayoutHashed, Depth: 2},
+			"benchmark/run/01/23/" + testFileName},
+		{"hashed deeper than the name", SiaPathLayout{Layout: LayoutHashed, Depth: 16},
+			"01/23/45/67/89/ab/cd/ef/01/23/45/67/89/ab/cd/ef/" + testFileName},
+		{"mirror", SiaPathLayout{Root: "benchmark/run", Layout: LayoutMirror},
+			"benchmark/run/" + mirrored + "/" + testFileName},
+	}
+	for _, test := range tests {
+		siaPath, err := test.layout.SiaPath(filepath.Join("queue", testFileName))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if siaPath.String() != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, siaPath, test.want)
+		}
+	}
+}
+
+func TestIsBenchmarkSiaPath(t *testing.T) {
+	var tests = []struct {
+		name, path, prefix string
+		want               bool
+	}{
+		{"flat", "benchmark/run/" + testFileName, "benchmark/", true},
+		{"hashed", "benchmark/run/01/23/" + testFileName, "benchmark/", true},
+		{"prefix with slashes", "benchmark/run/" + testFileName, "/benchmark/", true},
+		{"prefix without slash", "benchmark/run/" + testFileName, "benchmark", true},
+		{"outside the prefix", "backup/run/" + testFileName, "benchmark/", false},
+		{"prefix is part of a name", "benchmarks/run/" + testFileName, "benchmark", false},
+		{"other file in the prefix", "benchmark/run/notes.txt", "benchmark/", false},
+		{"upper case name", "benchmark/run/" + strings.ToUpper(testFileName[:32]) + ".dat", "benchmark/", false},
+		{"empty prefix", "photos/" + testFileName, "", false},
+		{"only slashes as prefix", "photos/" + testFileName, "/", false},
+		{"legacy", "01/2/" + testFileName, "benchmark/", true},
+		{"legacy with empty prefix", "01/2/" + testFileName, "", true},
+		{"legacy layout below a directory", "photos/01/2/" + testFileName, "", false},
+	}
+	for _, test := range tests {
+		siaPath, err := modules.NewSiaPath(test.path)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if got := IsBenchmarkSiaPath(siaPath, test.prefix); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package collector
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+)
+
+// UploadTracker keeps track of the files which were submitted by the benchmark
+// tool. Every time metrics are collected the tracker compares the state of the
+// files on the renter with the time they were submitted, this way we can see
+// how long it takes for a file to finish uploading and to reach full health.
+//
+// The tracker also watches the upload progress of all files which are being
+// uploaded. Files whose progress did not change for a number of rounds are
+// marked as stalled
+type UploadTracker struct {
+	window     time.Duration
+	stallLimit int
+
+	// Extra collections in between the measurement intervals don't count as
+	// a round for stall detection
+	extraRound bool
+
+	mutex     sync.Mutex
+	pending   map[modules.SiaPath]*trackedUpload
+	progress  map[modules.SiaPath]*uploadProgress
+	stalled   []StalledUpload
+	uploading []modules.FileInfo
+	failed    uint64
+
+	// Durations of the uploads which were completed within the window
+	uploaded []completedUpload
+	healthy  []completedUpload
+}
+
+type trackedUpload struct {
+	submitted time.Time
+	uploaded  bool
+}
+
+type uploadProgress struct {
+	progress float64
+	rounds   int
+}
+
+// StalledUpload is a file whose upload progress has not changed for at least
+// the configured number of rounds
+type StalledUpload struct {
+	modules.FileInfo
+
+	// Number of rounds without progress
+	Rounds int
+
+	// Whether the upload became stalled in the last round
+	New bool
+}
+
+type completedUpload struct {
+	completed time.Time
+	duration  time.Duration
+}
+
+// NewUploadTracker creates a new upload tracker. The window is the period over
+// which the upload latency percentiles are calculated. stallLimit is the number
+// of rounds a file's upload progress can stay the same before it's considered
+// stalled, 0 disables stall detection
+func NewUploadTracker(window time.Duration, stallLimit int) *UploadTracker {
+	return &UploadTracker{
+		window:     window,
+		stallLimit: stallLimit,
+		pending:    make(map[modules.SiaPath]*trackedUpload),
+		progress:   make(map[modules.SiaPath]*uploadProgress),
+	}
+}
+
+// Submit registers a file which was just submitted to the renter
+func (t *UploadTracker) Submit(siaPath modules.SiaPath) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.pending[siaPath] = &trackedUpload{submitted: time.Now()}
+}
+
+// SetExtraRound sets whether the next updates are extra collections in between
+// the measurement intervals. These don't advance the stall counters
+func (t *UploadTracker) SetExtraRound(extra bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.extraRound = extra
+}
+
+// Fail registers a file which could not be submitted to the renter
+func (t *UploadTracker) Fail() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.failed++
+}
+
+// update compares the file list from the renter with the pending uploads and
+// records the uploads which have completed since the last update. Files which
+// are no longer known to the renter are dropped. Files which were submitted
+// after the file list was requested are kept, they're not in the list yet
+func (t *UploadTracker) update(files []modules.FileInfo, now time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var seen = make(map[modules.SiaPath]bool, len(t.pending))
+	for _, file := range files {
+		upload, ok := t.pending[file.SiaPath]
+		if !ok {
+			continue
+		}
+		seen[file.SiaPath] = true
+
+		if !upload.uploaded && file.UploadProgress >= 100 {
+			upload.uploaded = true
+			t.uploaded = append(t.uploaded, completedUpload{
+				completed: now,
+				duration:  now.Sub(upload.submitted),
+			})
+		}
+		if file.UploadProgress >= 100 && file.MaxHealthPercent >= 100 {
+			t.healthy = append(t.healthy, completedUpload{
+				completed: now,
+				duration:  now.Sub(upload.submitted),
+			})
+			delete(t.pending, file.SiaPath)
+		}
+	}
+	for siaPath := range t.pending {
+		if !seen[siaPath] && t.pending[siaPath].submitted.Before(now) {
+			delete(t.pending, siaPath)
+		}
+	}
+
+	// Remove completed uploads which have fallen out of the window
+	t.uploaded = pruneCompleted(t.uploaded, now.Add(-t.window))
+	t.healthy = pruneCompleted(t.healthy, now.Add(-t.window))
+
+	t.uploading = t.uploading[:0]
+	for _, file := range files {
+		if file.UploadProgress < 100 && file.OnDisk {
+			t.uploading = append(t.uploading, file)
+		}
+	}
+
+	t.updateStalled(files)
+}
+
+// updateStalled compares the upload progress of the files which are being
+// uploaded with the progress in the previous round
+func (t *UploadTracker) updateStalled(files []modules.FileInfo) {
+	t.stalled = nil
+	if t.stallLimit <= 0 {
+		return
+	}
+
+	var uploading = make(map[modules.SiaPath]bool, len(t.progress))
+	for _, file := range files {
+		if file.UploadProgress >= 100 || !file.OnDisk {
+			continue
+		}
+		uploading[file.SiaPath] = true
+
+		prog, ok := t.progress[file.SiaPath]
+		if !ok || file.UploadProgress != prog.progress {
+			// New file or the upload has moved since the last round
+			t.progress[file.SiaPath] = &uploadProgress{progress: file.UploadProgress}
+			continue
+		}
+
+		if !t.extraRound {
+			prog.rounds++
+		}
+		if prog.rounds >= t.stallLimit {
+			t.stalled = append(t.stalled, StalledUpload{
+				FileInfo: file,
+				Rounds:   prog.rounds,
+				New:      !t.extraRound && prog.rounds == t.stallLimit,
+			})
+		}
+	}
+	for siaPath := range t.progress {
+		if !uploading[siaPath] {
+			delete(t.progress, siaPath)
+		}
+	}
+}
+
+// Stalled returns the files whose upload progress has not changed for the
+// configured number of rounds
+func (t *UploadTracker) Stalled() []StalledUpload {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]StalledUpload(nil), t.stalled...)
+}
+
+// Uploading returns the files which were being uploaded at the last update
+func (t *UploadTracker) Uploading() []modules.FileInfo {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]modules.FileInfo(nil), t.uploading...)
+}
+
+// resetStalled clears the stall counter of a file, this is used when a stalled
+// upload is restarted
+func (t *UploadTracker) resetStalled(siaPath modules.SiaPath) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.progress, siaPath)
+}
+
+// fillMetrics writes the upload latency percentiles to the metrics struct
+func (t *UploadTracker) fillMetrics(metrics *Metrics) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	metrics.UploadTimeP50,
+		metrics.UploadTimeP90,
+		metrics.UploadTimeP99,
+		metrics.UploadTimeMax = percentiles(t.uploaded)
+	metrics.HealthTimeP50,
+		metrics.HealthTimeP90,
+		metrics.HealthTimeP99,
+		metrics.HealthTimeMax = percentiles(t.healthy)
+	metrics.FileUploadsStalledCount = uint64(len(t.stalled))
+	metrics.FileUploadsFailedCount = t.failed
+}
+
+func pruneCompleted(completed []completedUpload, before time.Time) []completedUpload {
+	var i int
+	for i < len(completed) && completed[i].completed.Before(before) {
+		i++
+	}
+	return completed[i:]
+}
+
+// percentiles returns the 50th, 90th and 99th percentile and the maximum of the
+// completed upload durations
+func percentiles(completed []completedUpload) (p50, p90, p99, max time.Duration) {
+	if len(completed) == 0 {
+		return 0, 0, 0, 0
+	}
+
+	var durations = make([]time.Duration, len(completed))
+	for i, c := range completed {
+		durations[i] = c.duration
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	// Nearest-rank method
+	var rank = func(p int) time.Duration {
+		var i = (p*len(durations)+99)/100 - 1
+		if i < 0 {
+			i = 0
+		}
+		return durations[i]
+	}
+	return rank(50), rank(90), rank(99), durations[len(durations)-1]
+}
@@ -0,0 +1,159 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+)
+
+func durations(secs ...int) (completed []completedUpload) {
+	for _, s := range secs {
+		completed = append(completed, completedUpload{duration: time.Duration(s) * time.Second})
+	}
+	return completed
+}
+
+func seq(n int) (secs []int) {
+	for i := 1; i <= n; i++ {
+		secs = append(secs, i)
+	}
+	return secs
+}
+
+func TestPercentiles(t *testing.T) {
+	var tests = []struct {
+		name               string
+		completed          []completedUpload
+		p50, p90, p99, max int
+	}{
+		{"empty", nil, 0, 0, 0, 0},
+		{"one", durations(5), 5, 5, 5, 5},
+		{"two", durations(2, 1), 1, 2, 2, 2},
+		{"ten", durations(seq(10)...), 5, 9, 10, 10},
+		{"hundred", durations(seq(100)...), 50, 90, 99, 100},
+		{"hundred and one", durations(seq(101)...), 51, 91, 100, 101},
+		{"unsorted", durations(9, 3, 7, 1, 5), 5, 9, 9, 9},
+	}
+	for _, test := range tests {
+		p50, p90, p99, max := percentiles(test.completed)
+		var got = []time.Duration{p50, p90, p99, max}
+		var want = []int{test.p50, test.p90, test.p99, test.max}
+		for i := range got {
+			if got[i] != time.Duration(want[i])*time.Second {
+				t.Errorf("%s: got %v, want %v seconds", test.name, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestPruneCompleted(t *testing.T) {
+	var start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var completed []completedUpload
+	for i := 0; i < 5; i++ {
+		completed = append(completed, completedUpload{completed: start.Add(time.Duration(i) * time.Minute)})
+	}
+
+	var tests = []struct {
+		name   string
+		before time.Time
+		keep   int
+	}{
+		{"before all", start.Add(-time.Second), 5},
+		{"at first", start, 5},
+		{"just after first", start.Add(time.Second), 4},
+		{"at last", start.Add(4 * time.Minute), 1},
+		{"after all", start.Add(5 * time.Minute), 0},
+	}
+	for _, test := range tests {
+		if got := pruneCompleted(completed, test.before); len(got) != test.keep {
+			t.Errorf("%s: kept %d uploads, want %d", test.name, len(got), test.keep)
+		}
+	}
+}
+
+func TestUpdateDropsMissingUploads(t *testing.T) {
+	var now = time.Now()
+	var tracker = NewUploadTracker(time.Hour, 0)
+	var early, late = modules.RandomSiaPath(), modules.RandomSiaPath()
+	tracker.pending[early] = &trackedUpload{submitted: now.Add(-time.Minute)}
+	tracker.pending[late] = &trackedUpload{submitted: now.Add(time.Second)}
+
+	tracker.update(nil, now)
+	if _, ok := tracker.pending[early]; ok {
+		t.Error("upload which is missing from the file list was not dropped")
+	}
+	if _, ok := tracker.pending[late]; !ok {
+		t.Error("upload which was submitted after the file list was requested was dropped")
+	}
+}
+
+func TestUpdateRecordsLatency(t *testing.T) {
+	var now = time.Now()
+	var tracker = NewUploadTracker(time.Hour, 0)
+	var siaPath = modules.RandomSiaPath()
+	tracker.pending[siaPath] = &trackedUpload{submitted: now.Add(-time.Minute)}
+
+	tracker.update([]modules.FileInfo{{SiaPath: siaPath, UploadProgress: 100, MaxHealthPercent: 50}}, now)
+	if len(tracker.uploaded) != 1 || tracker.uploaded[0].duration != time.Minute {
+		t.Fatalf("upload latency not recorded: %v", tracker.uploaded)
+	}
+	if len(tracker.healthy) != 0 {
+		t.Fatal("file which is not healthy was recorded as healthy")
+	}
+
+	// Reaching full health is recorded once, after which the file is no
+	// longer tracked
+	tracker.update([]modules.FileInfo{{SiaPath: siaPath, UploadProgress: 100, MaxHealthPercent: 100}}, now.Add(time.Minute))
+	if len(tracker.uploaded) != 1 || len(tracker.healthy) != 1 || tracker.healthy[0].duration != 2*time.Minute {
+		t.Fatalf("health latency not recorded: %v %v", tracker.uploaded, tracker.healthy)
+	}
+	if _, ok := tracker.pending[siaPath]; ok {
+		t.Error("healthy file is still pending")
+	}
+}
+
+func TestStalledUploads(t *testing.T) {
+	var now = time.Now()
+	var tracker = NewUploadTracker(time.Hour, 2)
+	var file = modules.FileInfo{SiaPath: modules.RandomSiaPath(), UploadProgress: 50, OnDisk: true}
+
+	// rounds is the number of rounds without progress, -1 if the upload is
+	// not stalled
+	var tests = []struct {
+		name     string
+		extra    bool
+		progress float64
+		rounds   int
+		new      bool
+	}{
+		{"first round", false, 50, -1, false},
+		{"no progress for one round", false, 50, -1, false},
+		{"extra collection", true, 50, -1, false},
+		{"another extra collection", true, 50, -1, false},
+		{"no progress for two rounds", false, 50, 2, true},
+		{"extra collection while stalled", true, 50, 2, false},
+		{"no progress for three rounds", false, 50, 3, false},
+		{"progress", false, 60, -1, false},
+		{"no progress after moving", false, 60, -1, false},
+		{"stalled again", false, 60, 2, true},
+	}
+	for _, test := range tests {
+		file.UploadProgress = test.progress
+		tracker.SetExtraRound(test.extra)
+		tracker.update([]modules.FileInfo{file}, now)
+
+		var stalled = tracker.Stalled()
+		if test.rounds < 0 {
+			if len(stalled) != 0 {
+				t.Errorf("%s: upload is stalled", test.name)
+			}
+		} else if len(stalled) != 1 {
+			t.Errorf("%s: upload is not stalled", test.name)
+		} else if stalled[0].Rounds != test.rounds || stalled[0].New != test.new {
+			t.Errorf("%s: stalled for %d rounds, new %t, want %d rounds, new %t",
+				test.name, stalled[0].Rounds, stalled[0].New, test.rounds, test.new)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Fornaxian/log"
+)
+
+// controlServer is an HTTP API on a Unix socket which is used to change a
+// running test. The changes are picked up by the metrics loop
+type controlServer struct {
+	path string
+
+	mutex                sync.Mutex
+	paused               bool
+	maxConcurrentUploads uint64
+	fileSize             uint64
+	stopReason           string
+
+	// Receives a value when a collection is requested before the next
+	// interval
+	collect chan struct{}
+}
+
+// controlStatus is returned by every request to the control API
+type controlStatus struct {
+	Paused               bool   `json:"paused"`
+	MaxConcurrentUploads uint64 `json:"max_concurrent_uploads"`
+	FileSize             uint64 `json:"file_size"`
+	StopReason           string `json:"stop_reason,omitempty"`
+}
+
+// startControlServer starts listening on the control socket. A socket which is
+// left over from an earlier run is removed first, but a socket on which another
+// test answers is left alone
+func startControlServer(conf Configuration) (*controlServer, error) {
+	if testRunning(conf) {
+		return nil, fmt.Errorf("another test is running on %s", conf.ControlSocket)
+	}
+	if stat, err := os.Stat(conf.ControlSocket); err == nil && stat.Mode()&os.ModeSocket != 0 {
+		if err = os.Remove(conf.ControlSocket); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", conf.ControlSocket)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the user running the benchmark can control it
+	if err = os.Chmod(conf.ControlSocket, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	var c = &controlServer{
+		path:                 conf.ControlSocket,
+		maxConcurrentUploads: conf.MaxConcurrentUploads,
+		fileSize:             conf.FileSize,
+		collect:              make(chan struct{}, 1),
+	}
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/status", c.handle(nil))
+	mux.HandleFunc("/pause", c.handle(c.setPaused(true)))
+	mux.HandleFunc("/resume", c.handle(c.setPaused(false)))
+	mux.HandleFunc("/config", c.handle(c.setConfig))
+	mux.HandleFunc("/collect", c.handle(c.requestCollect))
+	mux.HandleFunc("/stop", c.handle(c.stop))
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Error("Control server stopped: %s", err)
+		}
+	}()
+	log.Info("Control API is listening on %s", conf.ControlSocket)
+	return c, nil
+}
+
+// close removes the control socket
+func (c *controlServer) close() {
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		log.Warn("Could not remove control socket: %s", err)
+	}
+}
+
+// apply copies the changed settings to the configuration. It returns whether
+// uploading is paused and the reason to stop the test, which is empty if the
+// test should continue
+func (c *controlServer) apply(conf *Configuration) (paused bool, stopReason string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if conf.MaxConcurrentUploads != c.maxConcurrentUploads {
+		log.Info("Changing max_concurrent_uploads from %d to %d", conf.MaxConcurrentUploads, c.maxConcurrentUploads)
+		conf.MaxConcurrentUploads = c.maxConcurrentUploads
+	}
+	if conf.FileSize != c.fileSize {
+		log.Info("Changing file_size from %s to %s", formatData(conf.FileSize), formatData(c.fileSize))
+		conf.FileSize = c.fileSize
+	}
+	return c.paused, c.stopReason
+}
+
+func (c *controlServer) status() controlStatus {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return controlStatus{
+		Paused:               c.paused,
+		MaxConcurrentUploads: c.maxConcurrentUploads,
+		FileSize:             c.fileSize,
+		StopReason:           c.stopReason,
+	}
+}
+
+// handle wraps a control action in an HTTP handler. Actions need a POST
+// request, the status can also be requested with GET. The response is always
+// the status after the action
+func (c *controlServer) handle(action func(r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if action != nil {
+			if r.Method != http.MethodPost {
+				http.Error(w, "use a POST request", http.StatusMethodNotAllowed)
+				return
+			}
+			if err := action(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.status())
+	}
+}
+
+func (c *controlServer) setPaused(paused bool) func(r *http.Request) error {
+	return func(r *http.Request) error {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		if c.paused != paused {
+			if paused {
+				log.Info("Uploading paused through the control API")
+			} else {
+				log.Info("Uploading resumed through the control API")
+			}
+		}
+		c.paused = paused
+		return nil
+	}
+}
+
+// setConfig changes max_concurrent_uploads and file_size. The new values are
+// used from the next interval
+func (c *controlServer) setConfig(r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	var values = make(map[string]uint64)
+	for _, name := range []string{"max_concurrent_uploads", "file_size"} {
+		if v := r.PostForm.Get(name); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil || n == 0 {
+				return fmt.Errorf("%s must be a number larger than 0", name)
+			}
+			values[name] = n
+		}
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("set max_concurrent_uploads or file_size")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if n, ok := values["max_concurrent_uploads"]; ok {
+		c.maxConcurrentUploads = n
+	}
+	if n, ok := values["file_size"]; ok {
+		c.fileSize = n
+	}
+	return nil
+}
+
+func (c *controlServer) requestCollect(r *http.Request) error {
+	select {
+	case c.collect <- struct{}{}:
+	default: // A collection is already requested
+	}
+	return nil
+}
+
+// stop ends the test after one more collection, so the final metrics are
+// saved
+func (c *controlServer) stop(r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	var reason = r.PostForm.Get("reason")
+	if reason == "" {
+		reason = "stopped through the control API"
+	}
+
+	c.mutex.Lock()
+	c.stopReason = reason
+	c.mutex.Unlock()
+	log.Info("Stop requested through the control API: %s", reason)
+	return c.requestCollect(r)
+}
+
+// controlClient returns an HTTP client which sends all requests to the control
+// socket, the host in the URL is ignored
+func controlClient(socket string) *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+// testRunning returns whether a test answers on the control socket
+func testRunning(conf Configuration) bool {
+	if conf.ControlSocket == "" {
+		return false
+	}
+	resp, err := controlClient(conf.ControlSocket).Get("http://benchmark/status")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// cmdControl sends a command to the control API of a running test
+func cmdControl(conf Configuration, fs *flag.FlagSet) {
+	if conf.ControlSocket == "" {
+		log.Error("control_socket is not configured")
+		os.Exit(2)
+	}
+
+	var path, form = "", url.Values{}
+	switch fs.Arg(0) {
+	case "status":
+		path = "/status"
+	case "pause", "resume", "collect":
+		path = "/" + fs.Arg(0)
+	case "set":
+		if fs.NArg() != 3 {
+			fs.Usage()
+			os.Exit(2)
+		}
+		path = "/config"
+		form.Set(fs.Arg(1), fs.Arg(2))
+	case "stop":
+		path = "/stop"
+		form.Set("reason", fs.Arg(1))
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var client = controlClient(conf.ControlSocket)
+	var resp *http.Response
+	var err error
+	if path == "/status" {
+		resp, err = client.Get("http://benchmark" + path)
+	} else {
+		resp, err = client.PostForm("http://benchmark"+path, form)
+	}
+	if err != nil {
+		log.Error("Could not reach the benchmark on %s: %s", conf.ControlSocket, err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Error("Could not read response: %s", err)
+		os.Exit(1)
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Error("Command failed: %s", body)
+		os.Exit(1)
+	}
+	fmt.Print(string(body))
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Fornax96/sia_benchmark/collector"
+	"github.com/Fornax96/sia_benchmark/report"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// ANSI escape codes used by the dashboard
+const (
+	ansiAltScreen  = "\x1b[?1049h"
+	ansiMainScreen = "\x1b[?1049l"
+	ansiHideCursor = "\x1b[?25l"
+	ansiShowCursor = "\x1b[?25h"
+	ansiHome       = "\x1b[H"
+	ansiClearLine  = "\x1b[K"
+	ansiClearDown  = "\x1b[J"
+	ansiBold       = "\x1b[1m"
+	ansiReset      = "\x1b[0m"
+)
+
+// Characters used for drawing sparklines, from low to high
+var sparkChars = []rune("▁▂▃▄▅▆▇█")
+
+// Number of log lines and speed samples the dashboard remembers
+const (
+	dashboardLogLines = 100
+	dashboardHistory  = 500
+)
+
+// dashboard is a full-screen terminal view of the benchmark, which is redrawn
+// every measurement interval. Everything the program writes to stdout is
+// captured while the dashboard is open, the last lines are shown at the bottom
+// of the screen
+type dashboard struct {
+	term      *os.File
+	restore   func() error
+	closeOnce sync.Once
+
+	// The write end of the pipe which captures stdout. done is closed when
+	// everything written to it has been read
+	pipe *os.File
+	done chan struct{}
+
+	mutex sync.Mutex
+	logs  []string
+
+	current []float64
+	average []float64
+}
+
+// newDashboard opens the dashboard. It fails when stdout is not a terminal or
+// when capturing stdout is not supported on this operating system
+func newDashboard() (*dashboard, error) {
+	stat, err := os.Stdout.Stat()
+	if err != nil {
+		return nil, err
+	} else if stat.Mode()&os.ModeCharDevice == 0 {
+		return nil, fmt.Errorf("stdout is not a terminal")
+	}
+
+	var d = &dashboard{done: make(chan struct{})}
+	logs, err := d.captureStdout()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer close(d.done)
+		var scanner = bufio.NewScanner(logs)
+		for scanner.Scan() {
+			d.mutex.Lock()
+			d.logs = append(d.logs, scanner.Text())
+			if len(d.logs) > dashboardLogLines {
+				d.logs = d.logs[len(d.logs)-dashboardLogLines:]
+			}
+			d.mutex.Unlock()
+		}
+	}()
+
+	fmt.Fprint(d.term, ansiAltScreen+ansiHideCursor)
+	return d, nil
+}
+
+// captureStdout redirects stdout to a pipe. The terminal is kept in d.term and
+// the output can be read from the returned file
+func (d *dashboard) captureStdout() (*os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	term, restore, err := redirectStdout(w)
+	if err != nil {
+		r.Close()
+		w.Close()
+		return nil, err
+	}
+	d.term = os.NewFile(term, "terminal")
+	d.restore = restore
+	d.pipe = w
+	return r, nil
+}
+
+// close restores the terminal and prints the log lines which were shown on
+// the dashboard, so they're not lost. Only the first call has any effect
+func (d *dashboard) close() {
+	d.closeOnce.Do(func() {
+		fmt.Fprint(d.term, ansiShowCursor+ansiMainScreen)
+		if err := d.restore(); err != nil {
+			fmt.Fprintf(d.term, "Could not restore stdout: %s\n", err)
+		}
+
+		// Wait until the last lines written to the pipe have been read
+		d.pipe.Close()
+		select {
+		case <-d.done:
+		case <-time.After(time.Second):
+		}
+
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		for _, line := range d.logs {
+			fmt.Fprintln(d.term, line)
+		}
+	})
+}
+
+// restoreOnPanic restores the terminal when the goroutine it's deferred in
+// panics, after which the panic continues. The dashboard can be nil
+func (d *dashboard) restoreOnPanic() {
+	if r := recover(); r != nil {
+		if d != nil {
+			d.close()
+		}
+		panic(r)
+	}
+}
+
+// draw redraws the dashboard with the latest metrics
+func (d *dashboard) draw(
+	metrics collector.Metrics,
+	bwCurrent, bwAverage uint64,
+	uploads []modules.FileInfo,
+	conf Configuration,
+	fiat *report.FiatRates,
+	runID string,
+) {
+	d.current = appendHistory(d.current, float64(bwCurrent))
+	d.average = appendHistory(d.average, float64(bwAverage))
+
+	var width, height = terminalSize(d.term)
+	var lines []string
+	var add = func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	var spent = func(c types.Currency) string {
+		if fiat == nil {
+			return c.HumanString()
+		}
+		return c.HumanString() + " (" + fiat.Format(report.Siacoins(c), metrics.Timestamp) + ")"
+	}
+
+	add(ansiBold+"Sia benchmark %s"+ansiReset+"  %s", runID, metrics.Timestamp.Format("2006-01-02 15:04:05"))
+	add("")
+	add("Files      %d  uploading %d  stalled %d  failed %d",
+		metrics.FileCount, metrics.FileUploadsInProgressCount,
+		metrics.FileUploadsStalledCount, metrics.FileUploadsFailedCount)
+	var expected float64
+	if metrics.ExpectedRedundancy > 0 {
+		expected = 100 / metrics.ExpectedRedundancy
+	}
+	add("Data       file %s  contract %s  efficiency %.2f%% (expected %.2f%%)",
+		formatData(metrics.FileTotalBytes), formatData(metrics.ContractSizeTotal),
+		metrics.Efficiency*100, expected)
+	add("Health     min %.1f%%  avg %.1f%%  redundancy min %.2f avg %.2f",
+		metrics.FileHealthMinPercent, metrics.FileHealthAvgPercent,
+		metrics.FileRedundancyMin, metrics.FileRedundancyAvg)
+	add("")
+
+	// Sparklines use the full width after the label
+	add("Current    %s/s", formatData(bwCurrent))
+	add("           %s", sparkline(d.current, width-11))
+	add("Average    %s/s", formatData(bwAverage))
+	add("           %s", sparkline(d.average, width-11))
+	add("")
+
+	add("Contracts  active %d  passive %d  refreshed %d  disabled %d  expired %d",
+		metrics.ContractCountActive, metrics.ContractCountPassive, metrics.ContractCountRefreshed,
+		metrics.ContractCountDisabled, metrics.ContractCountExpired)
+	add("Spending   total %s  unspent %s",
+		spent(metrics.ContractSpendingTotal),
+		spent(metrics.ContractFundsRemainingTotal))
+	add("           storage %s  upload %s  download %s  fees %s",
+		spent(metrics.ContractStorageSpendingTotal),
+		spent(metrics.ContractUploadSpendingTotal),
+		spent(metrics.ContractDownloadSpendingTotal),
+		spent(metrics.ContractFeeSpendingTotal))
+	add("Projection ETA %s  projected cost %s  allowance %s",
+		formatETA(metrics, conf),
+		spent(metrics.CostProjectedTotal),
+		formatAllowance(metrics))
+	add("")
+
+	// The rest of the screen is divided between the uploads and the logs
+	var free = height - len(lines) - 2
+	var uploadLines = free / 2
+	if uploadLines < 0 {
+		uploadLines = 0
+	} else if len(uploads) < uploadLines {
+		uploadLines = len(uploads)
+	}
+	add(ansiBold+"Uploads in progress (%d)"+ansiReset, len(uploads))
+	sort.Slice(uploads, func(i, j int) bool { return uploads[i].SiaPath.String() < uploads[j].SiaPath.String() })
+	for _, file := range uploads[:uploadLines] {
+		add("  %s %5.1f%%  %s", progressBar(file.UploadProgress, 20), file.UploadProgress, file.SiaPath)
+	}
+
+	add(ansiBold + "Log" + ansiReset)
+	d.mutex.Lock()
+	var logLines = d.logs
+	if n := height - len(lines); n >= 0 && len(logLines) > n {
+		logLines = logLines[len(logLines)-n:]
+	}
+	for _, line := range logLines {
+		add("  %s", line)
+	}
+	d.mutex.Unlock()
+
+	var b strings.Builder
+	b.WriteString(ansiHome)
+	for i, line := range lines {
+		if i >= height {
+			break
+		}
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(truncate(line, width))
+		b.WriteString(ansiClearLine)
+	}
+	b.WriteString(ansiClearDown)
+	fmt.Fprint(d.term, b.String())
+}
+
+func appendHistory(history []float64, v float64) []float64 {
+	history = append(history, v)
+	if len(history) > dashboardHistory {
+		history = history[len(history)-dashboardHistory:]
+	}
+	return history
+}
+
+// sparkline draws the last values of a series, scaled to the highest value
+func sparkline(values []float64, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	var max float64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		var i int
+		if max > 0 {
+			i = int(v / max * float64(len(sparkChars)-1))
+		}
+		b.WriteRune(sparkChars[i])
+	}
+	return b.String()
+}
+
+// progressBar draws a bar for a percentage
+func progressBar(percent float64, width int) string {
+	var filled = int(percent / 100 * float64(width))
+	if filled > width {
+		filled = width
+	} else if filled < 0 {
+		filled = 0
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
+
+// truncate cuts a line off at the width of the terminal. Escape codes don't
+// take up any space
+func truncate(line string, width int) string {
+	var b strings.Builder
+	var visible int
+	var escape bool
+	for _, r := range line {
+		switch {
+		case escape:
+			escape = r < '@' || r > '~' || r == '['
+		case r == '\x1b':
+			escape = true
+		default:
+			if visible >= width {
+				continue
+			}
+			visible++
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// defaultTerminalSize is used when the size of the terminal can't be measured
+func defaultTerminalSize() (width, height int) {
+	return 120, 40
+}
+
+// errDashboardUnsupported is returned on systems where stdout can't be
+// captured
+var errDashboardUnsupported = fmt.Errorf("the dashboard is not supported on this system")
@@ -0,0 +1,10 @@
+//go:build darwin || freebsd
+// +build darwin freebsd
+
+package main
+
+import "syscall"
+
+func dup2(oldfd, newfd int) error {
+	return syscall.Dup2(oldfd, newfd)
+}
@@ -0,0 +1,9 @@
+package main
+
+import "syscall"
+
+// Dup2 is not available on all architectures, Dup3 does the same with flags
+// set to 0
+func dup2(oldfd, newfd int) error {
+	return syscall.Dup3(oldfd, newfd, 0)
+}
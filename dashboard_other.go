@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package main
+
+import "os"
+
+// redirectStdout is not implemented on this operating system
+func redirectStdout(to *os.File) (orig uintptr, restore func() error, err error) {
+	return 0, nil, errDashboardUnsupported
+}
+
+// terminalSize is not implemented on this operating system
+func terminalSize(term *os.File) (width, height int) {
+	return defaultTerminalSize()
+}
@@ -0,0 +1,42 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// redirectStdout points the stdout file descriptor to another file. It returns
+// a new file descriptor for the original stdout and a function which restores
+// it
+func redirectStdout(to *os.File) (orig uintptr, restore func() error, err error) {
+	fd, err := syscall.Dup(syscall.Stdout)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err = dup2(int(to.Fd()), syscall.Stdout); err != nil {
+		syscall.Close(fd)
+		return 0, nil, err
+	}
+	return uintptr(fd), func() error { return dup2(fd, syscall.Stdout) }, nil
+}
+
+// terminalSize returns the number of columns and rows of a terminal
+func terminalSize(term *os.File) (width, height int) {
+	var size struct {
+		rows, cols, xpixel, ypixel uint16
+	}
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		term.Fd(),
+		uintptr(syscall.TIOCGWINSZ),
+		uintptr(unsafe.Pointer(&size)),
+	)
+	if errno != 0 || size.cols == 0 || size.rows == 0 {
+		return defaultTerminalSize()
+	}
+	return int(size.cols), int(size.rows)
+}
@@ -3,6 +3,7 @@ module github.com/Fornax96/sia_benchmark
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/Fornaxian/config v0.0.0-20180915150834-ac41cf746a70
 	github.com/Fornaxian/log v0.0.0-20190617093801-1c7ce9a7c9b3
 	gitlab.com/NebulousLabs/Sia v1.4.1
@@ -2,53 +2,123 @@ package main
 
 import (
 	"encoding/csv"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/Fornax96/sia_benchmark/collector"
-	"github.com/Fornaxian/config"
+	"github.com/Fornax96/sia_benchmark/report"
 	"github.com/Fornaxian/log"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/node/api"
 	sia "gitlab.com/NebulousLabs/Sia/node/api/client"
+	"gitlab.com/NebulousLabs/Sia/types"
+	"gitlab.com/NebulousLabs/fastrand"
 )
 
 // Configuration for the benchmark
 type Configuration struct {
 	// Sia API config
-	SiaAPIURL       string `toml:"sia_api_url"`
-	SiaAPIPassword  string `toml:"sia_api_password"`
-	SiaAPIUserAgent string `toml:"sia_api_user_agent"`
+	SiaAPIURL       string `toml:"sia_api_url" json:"sia_api_url"`
+	SiaAPIPassword  string `toml:"sia_api_password" json:"sia_api_password"`
+	SiaAPIUserAgent string `toml:"sia_api_user_agent" json:"sia_api_user_agent"`
 
-	WatchOnly bool `toml:"watch_only"`
+	WatchOnly bool `toml:"watch_only" json:"watch_only"`
 
 	// Allowance settings
-	Allowance        int    `toml:"allowance"`
-	AllowancePeriod  int    `toml:"allowance_period"`
-	HostCount        int    `toml:"host_count"`
-	FileDataPieces   uint64 `toml:"file_data_pieces"`
-	FileParityPieces uint64 `toml:"file_parity_pieces"`
+	Allowance        int    `toml:"allowance" json:"allowance"`
+	AllowancePeriod  int    `toml:"allowance_period" json:"allowance_period"`
+	HostCount        int    `toml:"host_count" json:"host_count"`
+	FileDataPieces   uint64 `toml:"file_data_pieces" json:"file_data_pieces"`
+	FileParityPieces uint64 `toml:"file_parity_pieces" json:"file_parity_pieces"`
 
 	// Test parameters
-	FileSize             uint64 `toml:"file_size"`
-	MaxConcurrentUploads uint64 `toml:"max_concurrent_uploads"`
-	MinUploadRate        uint64 `toml:"min_upload_rate"`
-	MeasurementInterval  uint   `toml:"measurement_interval"`
-	MeasurementPeriod    uint   `toml:"measurement_period"`
+	FileSize             uint64 `toml:"file_size" json:"file_size"`
+	MaxConcurrentUploads uint64 `toml:"max_concurrent_uploads" json:"max_concurrent_uploads"`
+	MinUploadRate        uint64 `toml:"min_upload_rate" json:"min_upload_rate"`
+	MeasurementInterval  uint   `toml:"measurement_interval" json:"measurement_interval"`
+	MeasurementPeriod    uint   `toml:"measurement_period" json:"measurement_period"`
+
+	// Period over which the upload latency percentiles are calculated
+	UploadLatencyWindow uint `toml:"upload_latency_window" json:"upload_latency_window"`
+
+	// Number of measurement intervals an upload can go without progress before
+	// it's considered stalled, and what to do with stalled uploads
+	StalledUploadIntervals int    `toml:"stalled_upload_intervals" json:"stalled_upload_intervals"`
+	StalledUploadPolicy    string `toml:"stalled_upload_policy" json:"stalled_upload_policy"`
+
+	// Whether to write contract metrics per host to a separate CSV
+	CollectHostMetrics bool `toml:"collect_host_metrics" json:"collect_host_metrics"`
+
+	// Whether to log changes in the renter's contract set
+	TrackContractEvents bool `toml:"track_contract_events" json:"track_contract_events"`
+
+	// Snapshots of the host DB are taken at the start and end of the test, and
+	// every interval if it's not 0
+	SnapshotHostDB         bool `toml:"snapshot_hostdb" json:"snapshot_hostdb"`
+	HostDBSnapshotInterval uint `toml:"hostdb_snapshot_interval" json:"hostdb_snapshot_interval"`
+
+	// Whether to collect consensus, gateway and transaction pool metrics
+	CollectChainMetrics bool `toml:"collect_chain_metrics" json:"collect_chain_metrics"`
+
+	// Whether to sample the resource usage of the siad process from /proc. If
+	// the PID is 0 the process is looked up by name
+	CollectProcessMetrics bool `toml:"collect_process_metrics" json:"collect_process_metrics"`
+	SiadPID               int  `toml:"siad_pid" json:"siad_pid"`
 
 	// How many bytes the Sia node needs to upload before the test is
 	// successful. If this is 0 the test will go on until the bandwidth
 	// thtreshold is crossed
-	SuccessSizeThreshold uint64 `toml:"success_size_threshold"`
+	SuccessSizeThreshold uint64 `toml:"success_size_threshold" json:"success_size_threshold"`
 
-	// Where the files will be generated and uploaded from
-	FileUploadsDir string `toml:"file_uploads_dir"`
+	// Where the files will be generated and uploaded from, and how much space
+	// needs to stay free on the disk
+	FileUploadsDir   string `toml:"file_uploads_dir" json:"file_uploads_dir"`
+	MinFreeDiskSpace uint64 `toml:"min_free_disk_space" json:"min_free_disk_space"`
+
+	// Where the files are placed on the renter. {run_id} in the root is
+	// replaced by the ID of the run
+	SiaPathRoot   string `toml:"siapath_root" json:"siapath_root"`
+	SiaPathLayout string `toml:"siapath_layout" json:"siapath_layout"`
+	SiaPathDepth  int    `toml:"siapath_depth" json:"siapath_depth"`
 
 	// Exit condition
-	StopSiaOnExit bool `toml:"stop_sia_on_exit"`
+	StopSiaOnExit bool `toml:"stop_sia_on_exit" json:"stop_sia_on_exit"`
+
+	// Start the test even if the preflight checks fail
+	ForceStart bool `toml:"force_start" json:"force_start"`
 
-	LoggingVerbosity int `toml:"logging_verbosity"`
+	// Every run gets its own directory in the results dir, where the manifest
+	// and metrics are saved
+	ResultsDir string `toml:"results_dir" json:"results_dir"`
+
+	// Seed for generating the file contents, in hex. If empty a random seed is
+	// generated
+	Seed string `toml:"seed" json:"seed"`
+
+	// Optional conversion of the spending to a fiat currency. The rate is the
+	// price of one siacoin, if a rates file is configured the rates are read
+	// from there instead
+	FiatCurrency  string  `toml:"fiat_currency" json:"fiat_currency"`
+	FiatRate      float64 `toml:"fiat_rate" json:"fiat_rate"`
+	FiatRatesFile string  `toml:"fiat_rates_file" json:"fiat_rates_file"`
+
+	// Show a full-screen dashboard instead of the table in the console
+	Dashboard bool `toml:"dashboard" json:"dashboard"`
+
+	// Address of the web dashboard, it's disabled when empty
+	WebDashboardAddress string `toml:"web_dashboard_address" json:"web_dashboard_address"`
+
+	// Path of the Unix socket of the control API, it's disabled when empty
+	ControlSocket string `toml:"control_socket" json:"control_socket"`
+
+	LoggingVerbosity int `toml:"logging_verbosity" json:"logging_verbosity"`
 }
 
 const defaultConfig = `# Sia benchmark tool configuration
@@ -82,6 +152,44 @@ measurement_interval   = 60 # one minute
 # bandwidth drops below 1 MB/s for two hours
 measurement_period     = 7200 # two hours
 
+# Upload latency percentiles are calculated over the files which finished
+# uploading within this period
+upload_latency_window  = 7200 # two hours
+
+# An upload is considered stalled when its progress has not changed for this
+# many measurement intervals. 0 disables stall detection. The policy decides
+# what happens with stalled uploads:
+#  - "report" logs them once, when they become stalled
+#  - "resubmit" deletes the file from Sia and uploads it again, only for files
+#    which were uploaded by the current run
+stalled_upload_intervals = 30
+stalled_upload_policy    = "report"
+
+# Write the contract size, spending and state of every host to host_metrics.csv
+# each measurement interval
+collect_host_metrics   = false
+
+# Compare the contracts between measurement intervals and write new contracts,
+# renewals, disables, re-enables and expirations to contract_events.csv
+track_contract_events  = false
+
+# Write the prices, scores, uptime and versions of all active hosts in the
+# renter's host DB to hostdb.csv at the start and end of the test. If the
+# interval is not 0 a snapshot is also taken every interval
+snapshot_hostdb          = false
+hostdb_snapshot_interval = 86400 # one day
+
+# Add the block height, sync status, peer count and transaction pool fee
+# estimates to metrics.csv
+collect_chain_metrics  = false
+
+# Add the CPU time, memory usage, open files, threads and disk and network I/O
+# of the siad process to metrics.csv. This reads from /proc so it only works on
+# Linux, with siad running on the same machine. If siad_pid is 0 the benchmark
+# tool looks for a process called siad
+collect_process_metrics = false
+siad_pid                = 0
+
 # How many bytes the Sia node needs to upload before the test is successful. If
 # this is 0 the test will go on until the bandwidth thtreshold is crossed
 success_size_threshold = 1000000000000 # 1 TB
@@ -89,42 +197,106 @@ success_size_threshold = 1000000000000 # 1 TB
 # Where the files will be generated and uploaded from
 file_uploads_dir       = "upload_queue"
 
+# No new files will be generated if that would leave less than this amount of
+# free space on the disk of the upload queue. Uploading resumes when finished
+# uploads are removed from the queue
+min_free_disk_space    = 10000000000 # 10 GB
+
+# Directory on the renter where the files of a run are uploaded to. {run_id} is
+# replaced by the ID of the run, so every run gets its own directory. The
+# layout decides how files are placed in that directory:
+#  - "flat" puts all files directly in the root
+#  - "hashed" spreads them over subdirectories named after the first characters
+#    of the file name, siapath_depth levels deep
+#  - "mirror" mirrors the local path of the upload queue
+siapath_root           = "benchmark/{run_id}"
+siapath_layout         = "hashed"
+siapath_depth          = 2
+
 # Exit condition. Whether to stop the Sia daemon if the test ends
 stop_sia_on_exit       = true
 
+# Before the test starts the Sia node is checked for problems which would
+# prevent the benchmark from working, like an unsynced consensus, a locked
+# wallet or too few contracts. The test won't start if a check fails, unless
+# force_start is enabled
+force_start            = false
+
+# Every run gets its own directory in here, containing the run manifest and the
+# metrics
+results_dir            = "runs"
+
+# Seed for generating the contents of the uploaded files, 64 hex characters. If
+# empty a random seed is used. The seed is saved in the run manifest
+seed                   = ""
+
+# Show the spending in a fiat currency as well as in siacoins, in the console
+# and in reports. Leave the currency empty to disable this. fiat_rate is the
+# price of one siacoin. Rates which change over time can be read from a CSV
+# file instead, with a timestamp (like 2020-01-02T15:04:05Z) and the price of
+# one siacoin on every line. The rate which was valid at the time of a
+# measurement is used
+fiat_currency          = ""
+fiat_rate              = 0.0
+fiat_rates_file        = ""
+
+# Show a full-screen dashboard with speed graphs, the uploads in progress, the
+# contracts, spending and the latest log messages instead of printing a table.
+# If the output is not a terminal the table is printed anyway
+dashboard              = false
+
+# Serve a web page with live charts of the metrics, the uploads in progress and
+# the status of the exit conditions on this address. For example
+# "127.0.0.1:8080", or ":8080" to allow access from other machines. There is no
+# authentication, so only expose it on a trusted network. Empty to disable
+web_dashboard_address  = ""
+
+# Path of a Unix socket for controlling a running test. Use "benchmark control"
+# to pause and resume uploading, change max_concurrent_uploads and file_size,
+# collect metrics right away or end the test. Empty to disable
+control_socket         = ""
+
 logging_verbosity      = 3 # 4 = debug, 3 = info, 2 = warning, 1 = error
 `
 
 func main() {
+	runCommand(os.Args[1:])
+}
+
+// runBenchmark connects to the Sia node and runs the benchmark until one of
+// the exit conditions is met. In watch only mode it runs forever
+func runBenchmark(conf Configuration) {
 	var err error
 
-	// Load the configuration
-	var conf = Configuration{}
-	if _, err = config.New(defaultConfig, "", "benchmark.toml", &conf, true); err != nil {
-		panic(err)
-	}
-	log.SetLogLevel(conf.LoggingVerbosity)
+	// The configuration has been validated before this function is called
 
-	// Check if uploads directory exists
-	dir, err := os.Stat(conf.FileUploadsDir)
+	conf.FileUploadsDir, err = filepath.Abs(conf.FileUploadsDir)
 	if !conf.WatchOnly && err != nil {
 		panic(err)
 	}
-	if !conf.WatchOnly && !dir.IsDir() {
-		log.Error("Upload queue directory %s is not a directory", conf.FileUploadsDir)
-		os.Exit(1)
-	}
 
-	conf.FileUploadsDir, err = filepath.Abs(conf.FileUploadsDir)
-	if !conf.WatchOnly && err != nil {
+	// The rates file is read again when creating reports, which can happen
+	// from another working directory
+	if conf.FiatRatesFile != "" {
+		if conf.FiatRatesFile, err = filepath.Abs(conf.FiatRatesFile); err != nil {
+			panic(err)
+		}
+	}
+	fiat, err := report.LoadFiatRates(conf.FiatCurrency, conf.FiatRate, conf.FiatRatesFile)
+	if err != nil {
 		panic(err)
 	}
 
 	var interval = time.Duration(conf.MeasurementInterval) * time.Second
 
-	sc := sia.New(conf.SiaAPIURL)
-	sc.Password = conf.SiaAPIPassword
-	sc.UserAgent = conf.SiaAPIUserAgent
+	sc := newSiaClient(conf)
+
+	// A second test with the same config would take over the control socket
+	// of the first one
+	if testRunning(conf) {
+		log.Error("A test is already running on %s, not starting another one", conf.ControlSocket)
+		os.Exit(1)
+	}
 
 	version, err := sc.DaemonVersionGet()
 	if err != nil {
@@ -132,32 +304,157 @@ func main() {
 	}
 	log.Info("Connected to Sia %s (rev %s)", version.Version, version.GitRevision)
 
-	// Open the metrics CSV
-	created := false
-	f, err := os.OpenFile("metrics.csv", os.O_WRONLY, os.ModeAppend)
-	if os.IsNotExist(err) {
-		f, err = os.Create("metrics.csv")
-		if err != nil {
-			panic(err)
+	if !conf.WatchOnly {
+		if failed := printPreflight(preflight(conf, sc)); failed > 0 {
+			if !conf.ForceStart {
+				log.Error("%d preflight check(s) failed, not starting the test", failed)
+				os.Exit(1)
+			}
+			log.Warn("%d preflight check(s) failed, starting anyway because force_start is enabled", failed)
 		}
-		created = true
-	} else if err != nil {
+	}
+
+	// Every run gets a unique ID and its own directory for the results
+	var startTime = time.Now()
+	var runID = newRunID(startTime)
+	runDir, err := createRunDir(conf.ResultsDir, runID)
+	if err != nil {
 		panic(err)
 	}
+	log.Info("Starting run %s, results will be saved in %s", runID, runDir)
 
-	// Append the data to the end of the file
-	f.Seek(0, os.SEEK_END)
+	var seed []byte
+	if conf.Seed == "" {
+		seed = fastrand.Bytes(32)
+	} else if seed, err = hex.DecodeString(conf.Seed); err != nil {
+		panic(err)
+	}
 
-	csvWriter := csv.NewWriter(f)
+	// Record the configuration and the system the benchmark runs on. The
+	// manifest is updated when the test ends
+	var manifest = Manifest{
+		RunID:       runID,
+		StartTime:   startTime,
+		SiaPathRoot: siaPathLayout(conf, runID).Root,
+		Seed:        hex.EncodeToString(seed),
+		Config:      redactConfig(conf),
+		System:      systemFingerprint(conf.FileUploadsDir, version),
+	}
+	var manifestPath = filepath.Join(runDir, "manifest.json")
+	if err = writeManifest(manifestPath, manifest); err != nil {
+		panic(err)
+	}
 
-	if created {
-		// New file, print headers
-		if err = csvWriter.Write(collector.MetricsHeaders()); err != nil {
+	if err = copyFiatRates(conf, runDir); err != nil {
+		panic(err)
+	}
+
+	var layout = siaPathLayout(conf, runID)
+	log.Info("Files will be uploaded to '%s' on the renter", layout.Root)
+
+	// The siapaths of all uploaded files are saved so they can be removed
+	// with the cleanup command
+	siaFiles, err := openSiaFileList(filepath.Join(runDir, "siafiles.txt"))
+	if err != nil {
+		panic(err)
+	}
+
+	// Open the metrics CSV
+	csvWriter, err := openCSV(filepath.Join(runDir, "metrics.csv"), collector.MetricsHeaders())
+	if err != nil {
+		panic(err)
+	}
+
+	var hostCollector *collector.HostCollector
+	var hostCSVWriter *csv.Writer
+	if conf.CollectHostMetrics {
+		hostCollector = collector.NewHostCollector()
+		if hostCSVWriter, err = openCSV(filepath.Join(runDir, "host_metrics.csv"), collector.HostMetricsHeaders()); err != nil {
 			panic(err)
 		}
-		csvWriter.Flush()
 	}
 
+	var contractWatcher *collector.ContractWatcher
+	var eventsCSVWriter *csv.Writer
+	if conf.TrackContractEvents {
+		contractWatcher = collector.NewContractWatcher()
+		if eventsCSVWriter, err = openCSV(filepath.Join(runDir, "contract_events.csv"), collector.ContractEventHeaders()); err != nil {
+			panic(err)
+		}
+	}
+
+	var lastHostDBSnapshot time.Time
+	var hostDBCSVWriter *csv.Writer
+	if conf.SnapshotHostDB {
+		if hostDBCSVWriter, err = openCSV(filepath.Join(runDir, "hostdb.csv"), collector.HostSnapshotHeaders()); err != nil {
+			panic(err)
+		}
+		snapshotHostDB(hostDBCSVWriter, sc, "start")
+		lastHostDBSnapshot = time.Now()
+		atExit = append(atExit, func(string) { snapshotHostDB(hostDBCSVWriter, sc, "exit") })
+	}
+
+	var processCollector *collector.ProcessCollector
+	if conf.CollectProcessMetrics {
+		processCollector = collector.NewProcessCollector(conf.SiadPID)
+	}
+
+	// The first and last block height seen during the test
+	var startHeight, endHeight types.BlockHeight
+	if conf.CollectChainMetrics {
+		atExit = append(atExit, func(string) {
+			log.Info("The test covered blocks %d to %d", startHeight, endHeight)
+		})
+	}
+
+	var web *webDashboard
+	if conf.WebDashboardAddress != "" {
+		if web, err = startWebDashboard(conf.WebDashboardAddress, runID); err != nil {
+			panic(fmt.Errorf("could not start web dashboard: %s", err))
+		}
+	}
+
+	var ctl *controlServer
+	var collectNow chan struct{} // Stays nil without control API
+	if conf.ControlSocket != "" {
+		if ctl, err = startControlServer(conf); err != nil {
+			panic(fmt.Errorf("could not start control API: %s", err))
+		}
+		collectNow = ctl.collect
+		atExit = append(atExit, func(string) { ctl.close() })
+	}
+
+	// The dashboard replaces the console table if stdout is a terminal. It's
+	// closed before the other exit functions run, so their output is visible.
+	// It's opened after everything which can fail during setup, and when the
+	// benchmark panics the terminal is restored before the panic is printed.
+	// Goroutines which are started after this point need to do the same
+	var dash *dashboard
+	if conf.Dashboard {
+		if dash, err = newDashboard(); err != nil {
+			log.Warn("Can't show the dashboard, printing a table instead: %s", err)
+		} else {
+			atExit = append([]func(string){func(string) { dash.close() }}, atExit...)
+		}
+	}
+	defer dash.restoreOnPanic()
+
+	// When the test is interrupted the metrics loop runs the exit functions
+	// after finishing the current round, they use the state of the loop. A
+	// second signal exits right away
+	var interrupted = make(chan string, 1)
+	go func() {
+		var sig = make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		interrupted <- fmt.Sprintf("received %s", <-sig)
+		<-sig
+		if dash != nil {
+			dash.close()
+		}
+		log.Warn("Interrupted again, exiting without saving the results")
+		os.Exit(1)
+	}()
+
 	// In this loop we collect stats on the
 	//  - Files
 	//  - Contracts
@@ -171,6 +468,50 @@ func main() {
 	// This struct saves all connected metrics from the siad API
 	var metrics collector.Metrics
 
+	// Save the exit reason and final metrics in the manifest when the test ends
+	atExit = append(atExit, func(reason string) {
+		var final = metrics
+		manifest.EndTime = time.Now()
+		manifest.ExitReason = reason
+		manifest.FinalMetrics = &final
+		manifest.StartHeight = startHeight
+		manifest.EndHeight = endHeight
+		if err := writeManifest(manifestPath, manifest); err != nil {
+			log.Error("Error while saving run manifest: %s", err)
+		}
+
+		// Rates which were added to the file during the test are included
+		// in the reports
+		if err := copyFiatRates(conf, runDir); err != nil {
+			log.Warn("Could not copy the fiat rates to the run directory: %s", err)
+		}
+	})
+
+	// Write the summary of the results when the test ends. This reads the
+	// manifest and metrics which were saved above
+	atExit = append(atExit, func(string) {
+		run, err := report.LoadRun(runDir)
+		if err != nil {
+			log.Error("Could not load results for the summary: %s", err)
+			return
+		}
+		if run.FiatErr != nil {
+			log.Warn("Spending in the summary is only shown in siacoins, %s", run.FiatErr)
+		}
+		if err = report.WriteSummary(runDir, report.Summarize(run)); err != nil {
+			log.Error("Error while saving run summary: %s", err)
+			return
+		}
+		log.Info("Summary of the results written to %s", filepath.Join(runDir, "summary.md"))
+	})
+
+	// The upload tracker measures how long it takes for the files we submit to
+	// finish uploading
+	var tracker = collector.NewUploadTracker(
+		time.Duration(conf.UploadLatencyWindow)*time.Second,
+		conf.StalledUploadIntervals,
+	)
+
 	// The bandwidth log saves bandwidth usage over the configured measurement
 	// period. The numbers in this array are averaged every round and stored in
 	// bwAverage to get the average bandwidth consumption. This number is used
@@ -180,34 +521,87 @@ func main() {
 	var bwAverage uint64
 	var bwFirstCycle = true
 	var uploading = false
+	var diskSpaceLow = false
 	for {
-		// Sleep until the next full minute
-		time.Sleep(time.Until(time.Now().Add(interval).Truncate(interval)))
+		// Sleep until the next full minute, until a collection is requested
+		// through the control API or until the test is interrupted
+		var extraCollection bool
+		select {
+		case <-time.After(time.Until(time.Now().Add(interval).Truncate(interval))):
+		case <-collectNow:
+			extraCollection = true
+		case reason := <-interrupted:
+			log.Warn("Test interrupted: %s", reason)
+			runExitFuncs(reason)
+			os.Exit(1)
+		}
+
+		var paused bool
+		var stopReason string
+		if ctl != nil {
+			paused, stopReason = ctl.apply(&conf)
+		}
 
-		if metrics, err = collector.CollectMetrics(sc); err != nil {
+		tracker.SetExtraRound(extraCollection)
+		var contracts api.RenterContracts
+		if metrics, contracts, err = collector.CollectMetrics(sc, tracker); err != nil {
 			log.Warn("Error while collecting metrics: %s", err)
 			continue
 		}
-
-		if err = metrics.WriteCSV(csvWriter); err != nil {
-			panic(fmt.Errorf("error while writing to CSV: %s", err))
+		metrics.ExpectedRedundancy = collector.ExpectedRedundancy(conf.FileDataPieces, conf.FileParityPieces)
+		collector.CalculateCosts(&metrics, conf.SuccessSizeThreshold)
+		if conf.CollectChainMetrics {
+			if err = collector.CollectChainMetrics(sc, &metrics); err != nil {
+				log.Warn("Error while collecting chain metrics: %s", err)
+			} else {
+				if startHeight == 0 {
+					startHeight = metrics.ConsensusHeight
+				}
+				endHeight = metrics.ConsensusHeight
+			}
 		}
-		if err = csvWriter.Error(); err != nil {
-			panic(fmt.Errorf("error while flushing CSV: %s", err))
+		if processCollector != nil {
+			if err = processCollector.Collect(&metrics); err != nil {
+				log.Warn("Error while collecting siad process metrics: %s", err)
+			}
 		}
 
-		// Reset the array index pointer to 0 when it's getting out of bounds
-		bwLogIndex++
-		if bwLogIndex == len(bwLog) {
-			bwLogIndex = 0
-			bwFirstCycle = false
+		var diskSlots uint64
+		if !conf.WatchOnly {
+			diskSlots = diskSpaceSlots(&metrics, conf)
+			if metrics.UploadsDirSpaceLow != diskSpaceLow {
+				if metrics.UploadsDirSpaceLow {
+					log.Warn(
+						"Only %s free in the upload queue, pausing uploads until %s is free",
+						formatData(metrics.UploadsDirFreeBytes),
+						formatData(conf.MinFreeDiskSpace+conf.FileSize))
+				} else {
+					log.Info("Enough free space in the upload queue again, resuming uploads")
+				}
+				diskSpaceLow = metrics.UploadsDirSpaceLow
+			}
 		}
 
-		// Overwrite the oldest digit in the bandwith log array
-		if lastSize != 0 && lastSize <= metrics.ContractSizeTotal {
-			bwLog[bwLogIndex] = (metrics.ContractSizeTotal - lastSize) / uint64(conf.MeasurementInterval)
+		// The bandwidth log is not updated while uploading is paused, so the
+		// pause doesn't lower the average speed. Extra collections don't cover
+		// a whole interval, so they are left out as well
+		if bwLogIndex == -1 || (!paused && !extraCollection) {
+			// Reset the array index pointer to 0 when it's getting out of
+			// bounds
+			bwLogIndex++
+			if bwLogIndex == len(bwLog) {
+				bwLogIndex = 0
+				bwFirstCycle = false
+			}
+
+			// Overwrite the oldest digit in the bandwith log array
+			if lastSize != 0 && lastSize <= metrics.ContractSizeTotal {
+				bwLog[bwLogIndex] = (metrics.ContractSizeTotal - lastSize) / uint64(conf.MeasurementInterval)
+			}
+		}
+		if !extraCollection || lastSize == 0 {
+			lastSize = metrics.ContractSizeTotal
 		}
-		lastSize = metrics.ContractSizeTotal
 
 		// Calculate average bandwidth
 		bwAverage = 0
@@ -220,49 +614,61 @@ func main() {
 			bwAverage = bwAverage / uint64(len(bwLog))
 		}
 
-		// Print test statistics
-		if bwLogIndex%30 == 0 {
-			// Print headers every 30 rows
-			fmt.Printf("%-30s  %-14s  %-5s  %-9s  %-9s  %-13s  %-10s  %-13s  %-13s  %-10s  %-10s\n",
-				"Timestamp",
-				"Latency",
-				"Files",
-				"Uploading",
-				"File Size",
-				"Contract Size",
-				"Efficiency",
-				"Current Speed",
-				"Average Speed",
-				"Spent",
-				"Unspent",
-			)
-		}
-		if metrics.ContractSizeTotal == 0 {
-			metrics.ContractSizeTotal = 1 // Avoid division by zero
-		}
-		fmt.Printf("%-30s  %-14s  %5d  %9d  %9s  %13s  %9.2f%%  %11s/s  %11s/s  %10s  %10s\n",
-			metrics.Timestamp.Format("2006-01-02 15:04:05 -0700 MST"), // Timestamp
-			metrics.APILatency,                    // Latency
-			metrics.FileCount,                     // Files
-			metrics.FileUploadsInProgressCount,    // Uploading
-			formatData(metrics.FileTotalBytes),    // File Size
-			formatData(metrics.ContractSizeTotal), // Contract Size
-			(float64(metrics.FileTotalBytes)/float64(metrics.ContractSizeTotal))*100, // Efficiency
-			formatData(bwLog[bwLogIndex]),                     // Current speed
-			formatData(bwAverage),                             // Avg. Speed
-			metrics.ContractSpendingTotal.HumanString(),       // Spent
-			metrics.ContractFundsRemainingTotal.HumanString(), // Unspent
-		)
+		// Project when the success threshold will be reached at the current
+		// average speed
+		collector.CalculateProjection(&metrics, bwAverage, conf.SuccessSizeThreshold)
+
+		if err = metrics.WriteCSV(csvWriter); err != nil {
+			panic(fmt.Errorf("error while writing to CSV: %s", err))
+		}
+		if err = csvWriter.Error(); err != nil {
+			panic(fmt.Errorf("error while flushing CSV: %s", err))
+		}
+
+		if hostCollector != nil {
+			writeHostMetrics(hostCollector.Collect(contracts, metrics.Timestamp), hostCSVWriter)
+		}
+		if contractWatcher != nil {
+			writeContractEvents(contractWatcher.Collect(contracts, metrics.Timestamp), eventsCSVWriter)
+		}
+
+		if hostDBCSVWriter != nil && conf.HostDBSnapshotInterval != 0 &&
+			time.Since(lastHostDBSnapshot) >= time.Duration(conf.HostDBSnapshotInterval)*time.Second {
+			snapshotHostDB(hostDBCSVWriter, sc, "interval")
+			lastHostDBSnapshot = time.Now()
+		}
+
+		// Stalled uploads are handled once per interval
+		if !extraCollection {
+			handleStalledUploads(tracker, conf, sc, siaFiles.contains)
+		}
+
+		// Print test statistics, headers are printed every 30 rows
+		if dash != nil {
+			dash.draw(metrics, bwLog[bwLogIndex], bwAverage, tracker.Uploading(), conf, fiat, runID)
+		} else {
+			printTableRow(metrics, bwLog[bwLogIndex], bwAverage, bwLogIndex%30 == 0, conf, fiat)
+		}
+		if web != nil {
+			web.publish(metrics, bwLog[bwLogIndex], bwAverage, tracker.Uploading(),
+				!bwFirstCycle && !conf.WatchOnly, conf, fiat)
+		}
 
 		// This function exits the program if the exit conditions are met. The
 		// test cannot end within one hour of starting
 		if !bwFirstCycle && !conf.WatchOnly {
 			testExitCondition(metrics, bwAverage, conf, sc)
 		}
+		if stopReason != "" {
+			log.Info(
+				"The test has ended with a total of %s uploaded in file data and %s uploaded in contract data",
+				formatData(metrics.FileTotalBytes), formatData(metrics.ContractSizeTotal))
+			stopTest(conf, sc, stopReason)
+		}
 
 		// Clean up finished uploads
 		if !conf.WatchOnly && !uploading {
-			if err = collector.FinishUploads(sc, conf.FileUploadsDir); err != nil {
+			if err = collector.FinishUploads(sc, layout, conf.FileUploadsDir, siaFiles.contains); err != nil {
 				log.Error("Error while removing finished uploads: %s", err)
 			}
 		}
@@ -270,35 +676,55 @@ func main() {
 		// Test conditions not met, continue uploading files. Here files are
 		// uploaded if:
 		//  - Watch Only mode is disabled
+		//  - Uploading is not paused through the control API
 		//  - There are not already files being uploaded
 		//  - There are upload slots available
+		//  - There is enough free disk space to generate a file
 		//  - There are enough contracts to support the file
 		//  - The total size of files is under the success threshold (to prevent
 		//    overshooting). Or the size threshold is disabled
-		if !conf.WatchOnly && !uploading &&
+		if !conf.WatchOnly && !paused && !uploading &&
 			metrics.FileUploadsInProgressCount < conf.MaxConcurrentUploads &&
+			diskSlots > 0 &&
 			uint64(metrics.ContractCountActive) >= conf.FileDataPieces+conf.FileParityPieces &&
 			(metrics.FileTotalBytes+(metrics.FileUploadsInProgressCount*conf.FileSize) < conf.SuccessSizeThreshold ||
 				conf.SuccessSizeThreshold == 0) {
 			uploading = true
 
+			var uploadCount = conf.MaxConcurrentUploads - metrics.FileUploadsInProgressCount
+			if uploadCount > diskSlots {
+				uploadCount = diskSlots
+			}
+
 			// This function can take a long time to run, so in order to not
-			// hold up the metrics loop is runs in a separate thread
+			// hold up the metrics loop is runs in a separate thread. The file
+			// size is copied because it can be changed through the control API
+			var fileSize = conf.FileSize
 			go func() {
+				defer dash.restoreOnPanic()
+
 				// Upload files concurrently in order to utilize all available
 				// CPU cores
 				wg := sync.WaitGroup{}
-				for i := uint64(0); i < conf.MaxConcurrentUploads-metrics.FileUploadsInProgressCount; i++ {
+				for i := uint64(0); i < uploadCount; i++ {
 					wg.Add(1)
 					go func() {
-						if err = collector.UploadFile(
+						defer dash.restoreOnPanic()
+						siaPath, err := collector.UploadFile(
 							sc,
+							tracker,
+							layout,
 							conf.FileUploadsDir,
+							seed,
 							conf.FileDataPieces,
 							conf.FileParityPieces,
-							conf.FileSize,
-						); err != nil {
+							fileSize,
+						)
+						if err != nil {
 							log.Warn("Failed to upload file to Sia: %s", err)
+							tracker.Fail()
+						} else if err = siaFiles.add(siaPath); err != nil {
+							log.Warn("Failed to record uploaded siapath: %s", err)
 						}
 						wg.Done()
 					}()
@@ -310,14 +736,172 @@ func main() {
 	}
 }
 
+// diskSpaceSlots measures the free space on the filesystem of the upload queue
+// and returns how many files can be generated without going below the
+// configured reserve. If the free space can't be measured the number of files
+// is not limited
+func diskSpaceSlots(metrics *collector.Metrics, conf Configuration) uint64 {
+	free, err := collector.FreeSpace(conf.FileUploadsDir)
+	if err != nil {
+		log.Debug("Could not measure free space in upload queue: %s", err)
+		return conf.MaxConcurrentUploads
+	}
+
+	var slots uint64
+	if free > conf.MinFreeDiskSpace {
+		slots = (free - conf.MinFreeDiskSpace) / conf.FileSize
+	}
+	metrics.UploadsDirFreeBytes = free
+	metrics.UploadsDirSpaceLow = slots == 0
+	return slots
+}
+
+// siaPathPrefix returns the part of siapath_root before the run ID, which is
+// the directory on the renter containing the files of all runs
+func siaPathPrefix(conf Configuration) string {
+	return strings.SplitN(conf.SiaPathRoot, "{run_id}", 2)[0]
+}
+
+// siaPathLayout returns the layout of the files on the renter for a run
+func siaPathLayout(conf Configuration, runID string) collector.SiaPathLayout {
+	return collector.SiaPathLayout{
+		Root:   strings.Replace(conf.SiaPathRoot, "{run_id}", runID, -1),
+		Layout: conf.SiaPathLayout,
+		Depth:  conf.SiaPathDepth,
+	}
+}
+
+// newSiaClient creates a Sia API client with the configured address and
+// credentials
+func newSiaClient(conf Configuration) *sia.Client {
+	sc := sia.New(conf.SiaAPIURL)
+	sc.Password = conf.SiaAPIPassword
+	sc.UserAgent = conf.SiaAPIUserAgent
+	return sc
+}
+
+// openCSV opens a CSV file for appending. If the file does not exist yet it is
+// created and the headers are written
+func openCSV(path string, headers []string) (*csv.Writer, error) {
+	created := false
+	f, err := os.OpenFile(path, os.O_WRONLY, os.ModeAppend)
+	if os.IsNotExist(err) {
+		f, err = os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		created = true
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Append the data to the end of the file
+	f.Seek(0, os.SEEK_END)
+
+	csvWriter := csv.NewWriter(f)
+
+	if created {
+		// New file, print headers
+		if err = csvWriter.Write(headers); err != nil {
+			return nil, err
+		}
+		csvWriter.Flush()
+	}
+	return csvWriter, csvWriter.Error()
+}
+
+// writeHostMetrics writes a snapshot of the contracts of every host to the
+// host metrics CSV
+func writeHostMetrics(hosts []collector.HostMetrics, csvWriter *csv.Writer) {
+	for _, host := range hosts {
+		if err := host.WriteCSV(csvWriter); err != nil {
+			panic(fmt.Errorf("error while writing to host CSV: %s", err))
+		}
+	}
+	if err := csvWriter.Error(); err != nil {
+		panic(fmt.Errorf("error while flushing host CSV: %s", err))
+	}
+}
+
+// writeContractEvents logs the changes in the renter's contract set since the
+// previous round and writes them to the contract events CSV
+func writeContractEvents(events []collector.ContractEvent, csvWriter *csv.Writer) {
+	for _, ev := range events {
+		log.Info(
+			"Contract %s with host %s %s (%s -> %s), cost %s, funds %s",
+			ev.ContractID, ev.NetAddress, ev.Event, ev.OldState, ev.NewState,
+			ev.TotalCost.HumanString(), ev.RenterFunds.HumanString())
+
+		if err := ev.WriteCSV(csvWriter); err != nil {
+			panic(fmt.Errorf("error while writing to contract events CSV: %s", err))
+		}
+	}
+	if err := csvWriter.Error(); err != nil {
+		panic(fmt.Errorf("error while flushing contract events CSV: %s", err))
+	}
+}
+
+// snapshotHostDB writes the state of all active hosts in the host DB to the
+// host DB CSV
+func snapshotHostDB(csvWriter *csv.Writer, sc *sia.Client, label string) {
+	hosts, err := collector.CollectHostDB(sc, label)
+	if err != nil {
+		log.Warn("Error while taking host DB snapshot: %s", err)
+		return
+	}
+	for _, host := range hosts {
+		if err = host.WriteCSV(csvWriter); err != nil {
+			panic(fmt.Errorf("error while writing to host DB CSV: %s", err))
+		}
+	}
+	if err = csvWriter.Error(); err != nil {
+		panic(fmt.Errorf("error while flushing host DB CSV: %s", err))
+	}
+	log.Info("Saved %s snapshot of %d hosts in the host DB", label, len(hosts))
+}
+
+// handleStalledUploads reports the uploads which have not made any progress
+// for the configured number of intervals and resubmits them if the policy says
+// so. Uploads are only handled in the interval they become stalled. Only files
+// which were uploaded by this run are resubmitted, owned tells which siapaths
+// those are
+func handleStalledUploads(
+	tracker *collector.UploadTracker,
+	conf Configuration,
+	sc *sia.Client,
+	owned func(modules.SiaPath) bool,
+) {
+	for _, file := range tracker.Stalled() {
+		if !file.New {
+			continue
+		}
+		log.Warn(
+			"Upload of '%s' is stalled at %.2f%% for %d intervals",
+			file.SiaPath, file.UploadProgress, file.Rounds)
+
+		if conf.WatchOnly || conf.StalledUploadPolicy != "resubmit" || !owned(file.SiaPath) {
+			continue
+		}
+
+		log.Info("Resubmitting stalled upload '%s'", file.SiaPath)
+		if err := collector.ResubmitUpload(
+			sc,
+			tracker,
+			file.FileInfo,
+			conf.FileDataPieces,
+			conf.FileParityPieces,
+		); err != nil {
+			log.Error("Failed to resubmit stalled upload: %s", err)
+		}
+	}
+}
+
 func testExitCondition(
 	metrics collector.Metrics,
 	bwAverage uint64,
 	conf Configuration,
 	sc *sia.Client,
 ) {
-	var err error
-
 	// Exit the test if bandwidth falls below the configured threshold
 	if bwAverage < conf.MinUploadRate {
 		log.Warn(
@@ -327,13 +911,7 @@ func testExitCondition(
 			"The test has ended with a total of %s uploaded in file data and %s uploaded in contract data",
 			formatData(metrics.FileTotalBytes), formatData(metrics.ContractSizeTotal))
 
-		if conf.StopSiaOnExit {
-			log.Info("Shutting down Sia...")
-			if err = sc.DaemonStopGet(); err != nil {
-				log.Error("Error stopping Sia daemon: %s", err)
-			}
-		}
-		os.Exit(0)
+		stopTest(conf, sc, "average upload speed fell below min_upload_rate")
 	}
 
 	// Exit the test if the total file size reaches the configured success
@@ -346,14 +924,37 @@ func testExitCondition(
 			"The test has ended with a total of %s uploaded in contract data and %s spent",
 			formatData(metrics.ContractSizeTotal), metrics.ContractSpendingTotal.HumanString())
 
-		if conf.StopSiaOnExit {
-			log.Info("Shutting down Sia...")
-			if err = sc.DaemonStopGet(); err != nil {
-				log.Error("Error stopping Sia daemon: %s", err)
-			}
+		stopTest(conf, sc, "total file size reached success_size_threshold")
+	}
+}
+
+// atExit contains functions which need to run before the benchmark tool exits.
+// They receive the reason why the test ended
+var atExit []func(reason string)
+var atExitOnce sync.Once
+
+// runExitFuncs runs the registered exit functions. They only run once, even if
+// this function is called multiple times
+func runExitFuncs(reason string) {
+	atExitOnce.Do(func() {
+		for _, f := range atExit {
+			f(reason)
+		}
+	})
+}
+
+// stopTest runs the exit functions, stops the Sia daemon if that's configured
+// and exits the program
+func stopTest(conf Configuration, sc *sia.Client, reason string) {
+	runExitFuncs(reason)
+
+	if conf.StopSiaOnExit {
+		log.Info("Shutting down Sia...")
+		if err := sc.DaemonStopGet(); err != nil {
+			log.Error("Error stopping Sia daemon: %s", err)
 		}
-		os.Exit(0)
 	}
+	os.Exit(0)
 }
 
 // FormatData converts a raw amount of bytes to an easily readable string
@@ -380,3 +981,101 @@ func formatData(v uint64) string {
 	}
 	return fmt.Sprintf("%5d  B", v)
 }
+
+// printTableRow prints the metrics as a row of the console table
+func printTableRow(
+	metrics collector.Metrics,
+	bwCurrent, bwAverage uint64,
+	header bool,
+	conf Configuration,
+	fiat *report.FiatRates,
+) {
+	if header {
+		fmt.Printf("%-30s  %-14s  %-5s  %-9s  %-9s  %-13s  %-10s  %-13s  %-13s  %-10s  %-10s  %-10s  %-10s  %-10s  %-9s  %-12s  %-14s  %-9s",
+			"Timestamp",
+			"Latency",
+			"Files",
+			"Uploading",
+			"File Size",
+			"Contract Size",
+			"Efficiency",
+			"Current Speed",
+			"Average Speed",
+			"Spent",
+			"Unspent",
+			"Upload p50",
+			"Upload p99",
+			"Health p99",
+			"Disk Free",
+			"ETA",
+			"Projected Cost",
+			"Allowance",
+		)
+		if fiat != nil {
+			fmt.Printf("  %-14s  %-14s  %-14s",
+				"Spent "+fiat.Currency, "Unspent "+fiat.Currency, "Projected "+fiat.Currency)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%-30s  %-14s  %5d  %9d  %9s  %13s  %9.2f%%  %11s/s  %11s/s  %10s  %10s  %10s  %10s  %10s  %9s  %12s  %14s  %9s",
+		metrics.Timestamp.Format("2006-01-02 15:04:05 -0700 MST"), // Timestamp
+		metrics.APILatency,                                // Latency
+		metrics.FileCount,                                 // Files
+		metrics.FileUploadsInProgressCount,                // Uploading
+		formatData(metrics.FileTotalBytes),                // File Size
+		formatData(metrics.ContractSizeTotal),             // Contract Size
+		metrics.Efficiency*100,                            // Efficiency
+		formatData(bwCurrent),                             // Current speed
+		formatData(bwAverage),                             // Avg. Speed
+		metrics.ContractSpendingTotal.HumanString(),       // Spent
+		metrics.ContractFundsRemainingTotal.HumanString(), // Unspent
+		formatDuration(metrics.UploadTimeP50),             // Upload p50
+		formatDuration(metrics.UploadTimeP99),             // Upload p99
+		formatDuration(metrics.HealthTimeP99),             // Health p99
+		formatData(metrics.UploadsDirFreeBytes),           // Disk Free
+		formatETA(metrics, conf),                          // ETA
+		metrics.CostProjectedTotal.HumanString(),          // Projected Cost
+		formatAllowance(metrics),                          // Allowance
+	)
+	if fiat != nil {
+		var convert = func(c types.Currency) string {
+			return fiat.Format(report.Siacoins(c), metrics.Timestamp)
+		}
+		fmt.Printf("  %14s  %14s  %14s",
+			convert(metrics.ContractSpendingTotal),       // Spent in fiat
+			convert(metrics.ContractFundsRemainingTotal), // Unspent in fiat
+			convert(metrics.CostProjectedTotal),          // Projected Cost in fiat
+		)
+	}
+	fmt.Println()
+}
+
+// formatETA formats the projected time until the success size threshold is
+// reached
+func formatETA(metrics collector.Metrics, conf Configuration) string {
+	if conf.SuccessSizeThreshold == 0 {
+		return "disabled"
+	} else if metrics.FileTotalBytes >= conf.SuccessSizeThreshold {
+		return "reached"
+	} else if metrics.ProjectedTimeRemaining == 0 {
+		return "unknown"
+	}
+	return formatDuration(metrics.ProjectedTimeRemaining)
+}
+
+// formatAllowance shows whether the allowance covers the projected cost
+func formatAllowance(metrics collector.Metrics) string {
+	switch metrics.ProjectedAllowance {
+	case collector.AllowanceEnough:
+		return "enough"
+	case collector.AllowanceTooLow:
+		return "too low"
+	default:
+		return "unknown"
+	}
+}
+
+// formatDuration rounds a duration to whole seconds for display in the console
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Fornax96/sia_benchmark/collector"
+	"github.com/Fornax96/sia_benchmark/report"
+	"gitlab.com/NebulousLabs/Sia/types"
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+// Manifest describes a benchmark run. It's written to manifest.json in the run
+// directory when the test starts and updated when the test ends, so the
+// results can be compared with other runs
+type Manifest struct {
+	RunID      string    `json:"run_id"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	ExitReason string    `json:"exit_reason"`
+	Seed       string    `json:"seed"`
+
+	// Directory on the renter which contains the files of this run
+	SiaPathRoot string `json:"siapath_root"`
+
+	Config Configuration `json:"config"`
+	System SystemInfo    `json:"system"`
+
+	// The first and last block height seen during the test. These are only
+	// known when chain metrics are collected
+	StartHeight types.BlockHeight `json:"start_height,omitempty"`
+	EndHeight   types.BlockHeight `json:"end_height,omitempty"`
+
+	// The last metrics which were collected before the test ended
+	FinalMetrics *collector.Metrics `json:"final_metrics"`
+}
+
+// newRunID generates a unique ID for a benchmark run. The ID starts with the
+// start time so run directories are sorted chronologically
+func newRunID(start time.Time) string {
+	return start.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(fastrand.Bytes(3))
+}
+
+// createRunDir creates the directory where the results of a run are saved
+func createRunDir(resultsDir, runID string) (dir string, err error) {
+	dir = filepath.Join(resultsDir, runID)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// redactConfig returns a copy of the configuration which is safe to share
+func redactConfig(conf Configuration) Configuration {
+	if conf.SiaAPIPassword != "" {
+		conf.SiaAPIPassword = "REDACTED"
+	}
+	return conf
+}
+
+// writeManifest saves the manifest as indented JSON
+func writeManifest(path string, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// copyFiatRates copies the fiat rates file to the run directory, so reports can
+// still be created when the run directory is moved to another machine
+func copyFiatRates(conf Configuration, runDir string) error {
+	if conf.FiatCurrency == "" || conf.FiatRatesFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(conf.FiatRatesFile)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(runDir, report.FiatRatesFileName), data, 0644)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Fornax96/sia_benchmark/collector"
+	sia "gitlab.com/NebulousLabs/Sia/node/api/client"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// preflightCheck is the result of one of the checks which are run against the
+// Sia node before the benchmark starts
+type preflightCheck struct {
+	name   string
+	passed bool
+	detail string
+
+	// Skipped checks could not be run on this system, they don't fail
+	skipped bool
+}
+
+// preflight checks if the Sia node is ready for benchmarking. It checks if
+// consensus is synced, if the wallet is unlocked and has enough funds for the
+// allowance, if an allowance is set, if there are enough contracts to upload
+// files with the configured redundancy and if the upload queue has enough room
+// for the files
+func preflight(conf Configuration, sc *sia.Client) (checks []preflightCheck) {
+	var check = func(name string, passed bool, format string, args ...interface{}) {
+		checks = append(checks, preflightCheck{
+			name:   name,
+			passed: passed,
+			detail: fmt.Sprintf(format, args...),
+		})
+	}
+
+	consensus, err := sc.ConsensusGet()
+	if err != nil {
+		check("Consensus is synced", false, "%s", err)
+	} else {
+		check("Consensus is synced", consensus.Synced, "height %d", consensus.Height)
+	}
+
+	wallet, err := sc.WalletGet()
+	if err != nil {
+		check("Wallet is unlocked", false, "%s", err)
+	} else {
+		check("Wallet is unlocked", wallet.Unlocked, "")
+	}
+
+	renter, err := sc.RenterGet()
+	if err != nil {
+		check("Allowance is set", false, "%s", err)
+	} else {
+		var allowance = renter.Settings.Allowance
+		check(
+			"Allowance is set", !allowance.Funds.IsZero(),
+			"%s for %d hosts", allowance.Funds.HumanString(), allowance.Hosts)
+
+		// The funds which are already allocated to contracts don't need to
+		// come from the wallet anymore
+		var needed types.Currency
+		if allowance.Funds.Cmp(renter.FinancialMetrics.TotalAllocated) > 0 {
+			needed = allowance.Funds.Sub(renter.FinancialMetrics.TotalAllocated)
+		}
+		if wallet.Unlocked {
+			check(
+				"Wallet balance covers the allowance",
+				wallet.ConfirmedSiacoinBalance.Cmp(needed) >= 0,
+				"balance %s, %s not allocated yet",
+				wallet.ConfirmedSiacoinBalance.HumanString(), needed.HumanString())
+		}
+	}
+
+	contracts, err := sc.RenterContractsGet()
+	if err != nil {
+		check("Enough active contracts", false, "%s", err)
+	} else {
+		var pieces = conf.FileDataPieces + conf.FileParityPieces
+		check(
+			"Enough active contracts",
+			uint64(len(contracts.ActiveContracts)) >= pieces,
+			"%d active, %d needed for %d data and %d parity pieces",
+			len(contracts.ActiveContracts), pieces, conf.FileDataPieces, conf.FileParityPieces)
+	}
+
+	var needed = conf.MaxConcurrentUploads*conf.FileSize + conf.MinFreeDiskSpace
+	free, err := collector.FreeSpace(conf.FileUploadsDir)
+	if err != nil {
+		// The free space is not limited during the test either
+		checks = append(checks, preflightCheck{
+			name:    "Upload queue has enough free space",
+			skipped: true,
+			detail:  err.Error(),
+		})
+	} else {
+		check(
+			"Upload queue has enough free space", free >= needed,
+			"%s free, %s needed including the %s reserve",
+			formatData(free), formatData(needed), formatData(conf.MinFreeDiskSpace))
+	}
+
+	return checks
+}
+
+// printPreflight prints the results of the preflight checks as a checklist and
+// returns the number of failed checks
+func printPreflight(checks []preflightCheck) (failed int) {
+	fmt.Println("Preflight checks:")
+	for _, c := range checks {
+		var status = "PASS"
+		if c.skipped {
+			status = "SKIP"
+		} else if !c.passed {
+			status = "FAIL"
+			failed++
+		}
+		if c.detail != "" {
+			fmt.Printf("  [%s] %s (%s)\n", status, c.name, c.detail)
+		} else {
+			fmt.Printf("  [%s] %s\n", status, c.name)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,274 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Ways of aligning runs in a comparison
+const (
+	// AlignTime compares the runs at the same time since their start
+	AlignTime = "time"
+
+	// AlignBytes compares the runs at the same amount of uploaded file data
+	AlignBytes = "bytes"
+)
+
+// Comparison compares the results of two or more runs. The first run is the
+// baseline which the others are compared with
+type Comparison struct {
+	Runs  []*Run
+	Align string
+}
+
+// runPoint contains the results of a run at the point where it's compared
+// with the other runs
+type runPoint struct {
+	time         time.Time
+	elapsed      time.Duration
+	fileBytes    float64
+	contractSize float64
+	spending     float64
+}
+
+func (p runPoint) efficiency() float64 {
+	if p.contractSize == 0 {
+		return 0
+	}
+	return p.fileBytes / p.contractSize
+}
+
+func (p runPoint) costPerTB() float64 {
+	if p.fileBytes == 0 {
+		return 0
+	}
+	return p.spending / (p.fileBytes / 1e12)
+}
+
+// alignX returns the X values of a run on the aligned axis
+func (c Comparison) alignX(run *Run) (x []float64) {
+	if c.Align == AlignBytes {
+		return run.Float("file_total_bytes")
+	}
+	for _, e := range run.Elapsed() {
+		x = append(x, e.Seconds())
+	}
+	return x
+}
+
+// formatX formats the values on the aligned axis
+func (c Comparison) formatX(v float64) string {
+	if c.Align == AlignBytes {
+		return formatData(v)
+	}
+	return formatElapsed(v)
+}
+
+// commonPoint returns the results of every run at the furthest point on the
+// aligned axis which was reached by all runs
+func (c Comparison) commonPoint() (common float64, points []runPoint) {
+	common = math.Inf(1)
+	for _, run := range c.Runs {
+		_, max := bounds(c.alignX(run))
+		common = math.Min(common, max)
+	}
+
+	for _, run := range c.Runs {
+		var x = c.alignX(run)
+		var i = sort.Search(len(x), func(i int) bool { return x[i] >= common })
+		if i == len(x) {
+			i = len(x) - 1
+		}
+		points = append(points, runPoint{
+			time:         run.times[i],
+			elapsed:      run.times[i].Sub(run.times[0]),
+			fileBytes:    run.Float("file_total_bytes")[i],
+			contractSize: run.Float("contract_size_total")[i],
+			spending:     run.Siacoins("contract_spending_total")[i],
+		})
+	}
+	return common, points
+}
+
+// fiatCurrency returns the fiat currency of the runs. It's empty if not all
+// runs have conversion to the same currency configured
+func (c Comparison) fiatCurrency() string {
+	for _, run := range c.Runs {
+		if run.Fiat == nil || run.Fiat.Currency != c.Runs[0].Fiat.Currency {
+			return ""
+		}
+	}
+	return c.Runs[0].Fiat.Currency
+}
+
+// speedStats returns the mean, median and standard deviation of the upload
+// speed of a run, calculated between every two samples
+func speedStats(run *Run) (mean, median, stddev float64) {
+	current, _ := run.Speeds()
+	if len(current) < 2 {
+		return 0, 0, 0
+	}
+	var speeds = append([]float64(nil), current[1:]...)
+
+	for _, v := range speeds {
+		mean += v
+	}
+	mean /= float64(len(speeds))
+	for _, v := range speeds {
+		stddev += (v - mean) * (v - mean)
+	}
+	stddev = math.Sqrt(stddev / float64(len(speeds)))
+
+	sort.Float64s(speeds)
+	if n := len(speeds); n%2 == 1 {
+		median = speeds[n/2]
+	} else {
+		median = (speeds[n/2-1] + speeds[n/2]) / 2
+	}
+	return mean, median, stddev
+}
+
+// table returns the comparison as rows of cells. The first row contains the
+// run IDs
+func (c Comparison) table() (rows [][]string) {
+	var common, points = c.commonPoint()
+
+	var header = []string{""}
+	for _, run := range c.Runs {
+		header = append(header, run.Manifest.RunID)
+	}
+	rows = append(rows, header)
+
+	// Every value is followed by the difference with the baseline
+	var addRow = func(name string, values []float64, format func(float64) string) {
+		var row = []string{name}
+		for i, v := range values {
+			var cell = format(v)
+			if i > 0 && values[0] != 0 {
+				cell += fmt.Sprintf(" (%+.1f%%)", (v-values[0])/values[0]*100)
+			}
+			row = append(row, cell)
+		}
+		rows = append(rows, row)
+	}
+	var collect = func(f func(i int) float64) (values []float64) {
+		for i := range c.Runs {
+			values = append(values, f(i))
+		}
+		return values
+	}
+
+	if c.Align == AlignBytes {
+		rows = append(rows, []string{"At file data", formatData(common)})
+		addRow("Elapsed time", collect(func(i int) float64 {
+			return points[i].elapsed.Seconds()
+		}), formatSeconds)
+	} else {
+		rows = append(rows, []string{"At elapsed time", formatSeconds(common)})
+		addRow("File data", collect(func(i int) float64 {
+			return points[i].fileBytes
+		}), formatData)
+	}
+	addRow("Contract data", collect(func(i int) float64 { return points[i].contractSize }), formatData)
+	addRow("Redundancy efficiency", collect(func(i int) float64 {
+		return points[i].efficiency() * 100
+	}), func(v float64) string { return fmt.Sprintf("%.2f%%", v) })
+	addRow("Spending", collect(func(i int) float64 { return points[i].spending }), formatSiacoins)
+	addRow("Cost per TB uploaded", collect(func(i int) float64 { return points[i].costPerTB() }), formatSiacoins)
+	if currency := c.fiatCurrency(); currency != "" {
+		var format = func(v float64) string { return formatFiat(v, currency) }
+		addRow("Spending in "+currency, collect(func(i int) float64 {
+			return c.Runs[i].Fiat.Convert(points[i].spending, points[i].time)
+		}), format)
+		addRow("Cost per TB uploaded in "+currency, collect(func(i int) float64 {
+			return c.Runs[i].Fiat.Convert(points[i].costPerTB(), points[i].time)
+		}), format)
+	}
+
+	var n = len(c.Runs)
+	var mean, median, stddev = make([]float64, n), make([]float64, n), make([]float64, n)
+	for i, run := range c.Runs {
+		mean[i], median[i], stddev[i] = speedStats(run)
+	}
+	addRow("Mean speed", mean, formatSpeed)
+	addRow("Median speed", median, formatSpeed)
+	addRow("Speed std. deviation", stddev, formatSpeed)
+	return rows
+}
+
+// WriteText writes the comparison as a table with a column for every run
+func (c Comparison) WriteText(w io.Writer) error {
+	var tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range c.table() {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// charts returns the charts comparing the runs, with a line for every run
+func (c Comparison) charts() []Chart {
+	var speed, efficiency, spending, progress []Series
+	for _, run := range c.Runs {
+		var x = c.alignX(run)
+		var name = run.Manifest.RunID
+		_, average := run.Speeds()
+		speed = append(speed, Series{Name: name, Values: average, X: x})
+		spending = append(spending, Series{Name: name, Values: run.Siacoins("contract_spending_total"), X: x})
+
+		var eff []float64
+		var file, contract = run.Float("file_total_bytes"), run.Float("contract_size_total")
+		for i := range file {
+			if contract[i] > 0 {
+				eff = append(eff, file[i]/contract[i]*100)
+			} else {
+				eff = append(eff, 0)
+			}
+		}
+		efficiency = append(efficiency, Series{Name: name, Values: eff, X: x})
+
+		// The progress chart shows what the runs were not aligned by
+		if c.Align == AlignBytes {
+			var elapsed []float64
+			for _, e := range run.Elapsed() {
+				elapsed = append(elapsed, e.Seconds())
+			}
+			progress = append(progress, Series{Name: name, Values: elapsed, X: x})
+		} else {
+			progress = append(progress, Series{Name: name, Values: file, X: x})
+		}
+	}
+
+	var progressChart = Chart{Title: "File data", FormatY: formatData, Series: progress}
+	if c.Align == AlignBytes {
+		progressChart = Chart{Title: "Elapsed time", FormatY: formatSeconds, Series: progress}
+	}
+	var charts = []Chart{
+		progressChart,
+		{Title: "Average upload speed", FormatY: formatSpeed, Series: speed},
+		{Title: "Redundancy efficiency", FormatY: formatPercent, Series: efficiency},
+		{Title: "Spending", FormatY: formatSiacoins, Series: spending},
+	}
+	for i := range charts {
+		charts[i].FormatX = c.formatX
+	}
+	return charts
+}
+
+// WriteHTML writes the comparison as a self-contained HTML page with the table
+// and charts of the runs
+func (c Comparison) WriteHTML(w io.Writer) error {
+	var table = c.table()
+	return pageTemplate.Execute(w, page{
+		Title:  fmt.Sprintf("Sia benchmark comparison of %d runs", len(c.Runs)),
+		Header: table[0],
+		Rows:   table[1:],
+		Charts: c.charts(),
+	})
+}
+
+func formatPercent(v float64) string { return fmt.Sprintf("%.1f%%", v) }
@@ -0,0 +1,118 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// FiatRatesFileName is the name of the copy of the rates file in the run
+// directory
+const FiatRatesFileName = "fiat_rates.csv"
+
+// FiatRates converts siacoins to a fiat currency. The rate can be fixed or
+// change over time, in which case the rate which was valid when the spending
+// was measured is used. A nil *FiatRates means conversion is disabled
+type FiatRates struct {
+	Currency string
+	rates    []fiatRate
+}
+
+type fiatRate struct {
+	time time.Time
+	rate float64
+}
+
+// LoadFiatRates creates the exchange rates for a currency. If a rates file is
+// given the rates are read from it, otherwise the fixed rate is used. The rates
+// file is a CSV file with an RFC 3339 timestamp and the price of one siacoin on
+// every line. Lines starting with # are ignored. If the currency is empty nil
+// is returned
+func LoadFiatRates(currency string, rate float64, ratesFile string) (*FiatRates, error) {
+	if currency == "" {
+		return nil, nil
+	}
+	var fiat = &FiatRates{Currency: currency}
+
+	if ratesFile == "" {
+		if rate <= 0 {
+			return nil, fmt.Errorf("no exchange rate for %s", currency)
+		}
+		fiat.rates = []fiatRate{{rate: rate}}
+		return fiat, nil
+	}
+
+	f, err := os.Open(ratesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var reader = csv.NewReader(f)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read %s: %s", ratesFile, err)
+		}
+
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp in %s: %s", ratesFile, err)
+		}
+		r, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil || r <= 0 {
+			return nil, fmt.Errorf("invalid rate '%s' in %s", record[1], ratesFile)
+		}
+		fiat.rates = append(fiat.rates, fiatRate{time: t, rate: r})
+	}
+	if len(fiat.rates) == 0 {
+		return nil, fmt.Errorf("%s does not contain any rates", ratesFile)
+	}
+
+	sort.Slice(fiat.rates, func(i, j int) bool {
+		return fiat.rates[i].time.Before(fiat.rates[j].time)
+	})
+	return fiat, nil
+}
+
+// Rate returns the price of one siacoin at a point in time. This is the last
+// rate from before that time, or the first rate if there is none
+func (f *FiatRates) Rate(t time.Time) float64 {
+	var i = sort.Search(len(f.rates), func(i int) bool { return f.rates[i].time.After(t) })
+	if i > 0 {
+		i--
+	}
+	return f.rates[i].rate
+}
+
+// Convert converts an amount of siacoins to the fiat currency
+func (f *FiatRates) Convert(sc float64, t time.Time) float64 {
+	return sc * f.Rate(t)
+}
+
+// Format converts an amount of siacoins and formats it with the currency code
+func (f *FiatRates) Format(sc float64, t time.Time) string {
+	return formatFiat(f.Convert(sc, t), f.Currency)
+}
+
+// Siacoins converts a currency value in hastings to siacoins
+func Siacoins(c types.Currency) float64 {
+	f, _ := c.Float64()
+	return f / hastingsPerSiacoin
+}
+
+func formatFiat(v float64, currency string) string {
+	return fmt.Sprintf("%.2f %s", v, currency)
+}
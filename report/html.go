@@ -0,0 +1,160 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+)
+
+// Row is a row in the headline table of a report
+type Row struct {
+	Name  string
+	Value string
+}
+
+// headlineRows returns the rows of the headline table
+func headlineRows(s Summary) []Row {
+	var version = s.SiaVersion
+	if version == "" {
+		version = "unknown"
+	}
+	var blocks = "unknown"
+	if s.EndHeight > 0 {
+		blocks = fmt.Sprintf("%d to %d", s.StartHeight, s.EndHeight)
+	}
+	return []Row{
+		{"Run ID", s.RunID},
+		{"Start time", s.StartTime.UTC().Format("2006-01-02 15:04:05 UTC")},
+		{"Duration", s.Duration.Round(1e9).String()},
+		{"Exit reason", s.ExitReason},
+		{"Sia version", version},
+		{"Blocks", blocks},
+		{"Files uploaded", fmt.Sprintf("%d", s.FileCount)},
+		{"File data", formatData(float64(s.FileTotalBytes))},
+		{"Contract data", formatData(float64(s.ContractSizeTotal))},
+		{"Redundancy efficiency", fmt.Sprintf("%.2f%%", s.Efficiency*100)},
+		{"Average speed", formatSpeed(s.AverageSpeed)},
+		{"Peak speed", formatSpeed(s.PeakSpeed)},
+		{"Total spending", s.formatSiacoins(s.SpendingTotal)},
+		{"Cost per TB uploaded", s.formatSiacoins(s.CostPerTBUploaded)},
+		{"Cost per TB stored per month", s.formatSiacoins(s.CostPerTBMonth)},
+		{"Failed uploads", fmt.Sprintf("%d", s.FailedUploads)},
+		{"Most stalled uploads", fmt.Sprintf("%d", s.StalledUploads)},
+	}
+}
+
+// runCharts returns the charts which are shown in the report of a run
+func runCharts(run *Run) []Chart {
+	var x []float64
+	for _, e := range run.Elapsed() {
+		x = append(x, e.Seconds())
+	}
+	var current, average = run.Speeds()
+
+	var charts = []Chart{{
+		Title: "Contract size vs file size", X: x, FormatX: formatElapsed, FormatY: formatData,
+		Series: []Series{
+			{Name: "Contract size", Values: run.Float("contract_size_total")},
+			{Name: "File size", Values: run.Float("file_total_bytes")},
+		},
+	}, {
+		Title: "Upload speed", X: x, FormatX: formatElapsed, FormatY: formatSpeed,
+		Series: []Series{
+			{Name: "Current", Values: current},
+			{Name: "Average", Values: average},
+		},
+	}, {
+		Title: "Spending by category", X: x, FormatX: formatElapsed, FormatY: formatSiacoins,
+		Series: []Series{
+			{Name: "Storage", Values: run.Siacoins("contract_storage_spending_total")},
+			{Name: "Upload", Values: run.Siacoins("contract_upload_spending_total")},
+			{Name: "Download", Values: run.Siacoins("contract_download_spending_total")},
+			{Name: "Fees", Values: run.Siacoins("contract_fee_spending_total")},
+		},
+	}, {
+		Title: "Contracts by state", X: x, FormatX: formatElapsed, FormatY: formatCount,
+		Series: []Series{
+			{Name: "Active", Values: run.Float("contract_count_active")},
+			{Name: "Passive", Values: run.Float("contract_count_passive")},
+			{Name: "Refreshed", Values: run.Float("contract_count_refreshed")},
+			{Name: "Disabled", Values: run.Float("contract_count_disabled")},
+			{Name: "Expired", Values: run.Float("contract_count_expired")},
+		},
+	}, {
+		Title: "API latency", X: x, FormatX: formatElapsed, FormatY: formatSeconds,
+		Series: []Series{
+			{Name: "Latency", Values: run.Seconds("api_latency")},
+		},
+	}}
+
+	// The spending in the fiat currency uses the exchange rate at the time of
+	// every sample
+	if run.Fiat != nil {
+		var fiat = Chart{
+			Title: "Spending in " + run.Fiat.Currency, X: x, FormatX: formatElapsed,
+			FormatY: func(v float64) string { return formatFiat(v, run.Fiat.Currency) },
+			Series: []Series{
+				{Name: "Total", Values: run.Converted("contract_spending_total")},
+				{Name: "Storage", Values: run.Converted("contract_storage_spending_total")},
+				{Name: "Upload", Values: run.Converted("contract_upload_spending_total")},
+				{Name: "Fees", Values: run.Converted("contract_fee_spending_total")},
+			},
+		}
+		charts = append(charts[:3], append([]Chart{fiat}, charts[3:]...)...)
+	}
+	return charts
+}
+
+func formatCount(v float64) string { return fmt.Sprintf("%.0f", v) }
+
+// page is the data of an HTML report. The header of the table is optional
+type page struct {
+	Title  string
+	Header []string
+	Rows   [][]string
+	Charts []Chart
+}
+
+// WriteHTML writes a self-contained HTML report of a run, with a table of the
+// final results and charts of the metrics
+func WriteHTML(w io.Writer, run *Run) error {
+	var rows [][]string
+	for _, row := range headlineRows(Summarize(run)) {
+		rows = append(rows, []string{row.Name, row.Value})
+	}
+	return pageTemplate.Execute(w, page{
+		Title:  "Sia benchmark " + run.Manifest.RunID,
+		Rows:   rows,
+		Charts: runCharts(run),
+	})
+}
+
+var pageTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { padding: 4px 12px; border-bottom: 1px solid #ddd; text-align: left; }
+td:first-child { font-weight: bold; }
+.chart { width: 100%; margin-bottom: 2em; }
+.chart .title { font-size: 16px; font-weight: bold; }
+.chart .grid { stroke: #ddd; }
+.chart .line { fill: none; stroke-width: 2; }
+.chart .xlabel { font-size: 11px; text-anchor: middle; }
+.chart .ylabel { font-size: 11px; text-anchor: end; }
+.chart .legend { font-size: 12px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+{{if .Header}}<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{end}}{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{range .Charts}}{{.SVG}}
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,231 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// hastingsPerSiacoin is used for converting the currency values in the CSV,
+// which are in hastings
+const hastingsPerSiacoin = 1e24
+
+// Run contains the manifest and the metrics of a benchmark run, as read from
+// the run directory
+type Run struct {
+	Dir      string
+	Manifest Manifest
+
+	// The full configuration from the manifest, which is included in
+	// summaries as is
+	Config json.RawMessage
+
+	// Conversion of spending to a fiat currency, nil if not configured or if
+	// the rates could not be loaded. FiatErr is the reason loading failed
+	Fiat    *FiatRates
+	FiatErr error
+
+	columns map[string]int
+	rows    [][]string
+	times   []time.Time
+}
+
+// Manifest contains the fields of the run manifest which are used in reports
+type Manifest struct {
+	RunID      string    `json:"run_id"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	ExitReason string    `json:"exit_reason"`
+
+	// Block range of the test, zero if chain metrics were not collected
+	StartHeight uint64 `json:"start_height"`
+	EndHeight   uint64 `json:"end_height"`
+
+	Config struct {
+		MeasurementPeriod uint    `json:"measurement_period"`
+		FileDataPieces    uint64  `json:"file_data_pieces"`
+		FileParityPieces  uint64  `json:"file_parity_pieces"`
+		FileSize          uint64  `json:"file_size"`
+		FiatCurrency      string  `json:"fiat_currency"`
+		FiatRate          float64 `json:"fiat_rate"`
+		FiatRatesFile     string  `json:"fiat_rates_file"`
+	} `json:"config"`
+	System struct {
+		SiaVersion     string `json:"sia_version"`
+		SiaGitRevision string `json:"sia_git_revision"`
+	} `json:"system"`
+}
+
+// LoadRun reads the manifest and metrics of a run. The manifest is optional,
+// so metrics.csv files from before run directories existed can be read too
+func LoadRun(dir string) (run *Run, err error) {
+	run = &Run{Dir: dir, columns: make(map[string]int)}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err == nil {
+		var raw struct {
+			Config json.RawMessage `json:"config"`
+		}
+		if err = json.Unmarshal(data, &run.Manifest); err == nil {
+			err = json.Unmarshal(data, &raw)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse run manifest: %s", err)
+		}
+		run.Config = raw.Config
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if run.Manifest.RunID == "" {
+		run.Manifest.RunID = filepath.Base(dir)
+	}
+
+	// The rates file is copied to the run directory when the test starts, the
+	// configured path is only used for runs from before that
+	var conf = run.Manifest.Config
+	var ratesFile = conf.FiatRatesFile
+	if ratesFile != "" {
+		if _, err = os.Stat(filepath.Join(dir, FiatRatesFileName)); err == nil {
+			ratesFile = filepath.Join(dir, FiatRatesFileName)
+		}
+	}
+	if run.Fiat, err = LoadFiatRates(conf.FiatCurrency, conf.FiatRate, ratesFile); err != nil {
+		run.FiatErr = fmt.Errorf("could not load fiat exchange rates: %s", err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "metrics.csv"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var reader = csv.NewReader(f)
+	reader.FieldsPerRecord = -1 // Older files can have a different number of columns
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not read metrics: %s", err)
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("no metrics were collected in %s", dir)
+	}
+
+	for i, header := range rows[0] {
+		run.columns[header] = i
+	}
+	run.rows = rows[1:]
+
+	for _, v := range run.column("timestamp") {
+		t, err := time.Parse("2006-01-02T15:04:05Z", v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp '%s': %s", v, err)
+		}
+		run.times = append(run.times, t)
+	}
+	return run, nil
+}
+
+// Len returns the number of metrics samples in the run
+func (run *Run) Len() int { return len(run.rows) }
+
+// Times returns the timestamps of all the samples
+func (run *Run) Times() []time.Time { return run.times }
+
+// Elapsed returns the time since the first sample for all the samples
+func (run *Run) Elapsed() (elapsed []time.Duration) {
+	for _, t := range run.times {
+		elapsed = append(elapsed, t.Sub(run.times[0]))
+	}
+	return elapsed
+}
+
+// column returns the raw values of a column. If the column does not exist all
+// values are empty
+func (run *Run) column(name string) (values []string) {
+	i, ok := run.columns[name]
+	values = make([]string, len(run.rows))
+	if !ok {
+		return values
+	}
+	for j, row := range run.rows {
+		if i < len(row) {
+			values[j] = row[i]
+		}
+	}
+	return values
+}
+
+// Float returns the values of a numeric column. Values which can't be parsed
+// are 0
+func (run *Run) Float(name string) (values []float64) {
+	for _, v := range run.column(name) {
+		f, _ := strconv.ParseFloat(v, 64)
+		values = append(values, f)
+	}
+	return values
+}
+
+// Siacoins returns the values of a currency column converted to siacoins
+func (run *Run) Siacoins(name string) (values []float64) {
+	for _, v := range run.Float(name) {
+		values = append(values, v/hastingsPerSiacoin)
+	}
+	return values
+}
+
+// Converted returns the values of a currency column converted to the fiat
+// currency, with the exchange rate at the time of every sample. Nil if no
+// conversion is configured
+func (run *Run) Converted(name string) (values []float64) {
+	if run.Fiat == nil {
+		return nil
+	}
+	for i, v := range run.Siacoins(name) {
+		values = append(values, run.Fiat.Convert(v, run.times[i]))
+	}
+	return values
+}
+
+// Seconds returns the values of a duration column in seconds
+func (run *Run) Seconds(name string) (values []float64) {
+	for _, v := range run.column(name) {
+		d, _ := time.ParseDuration(v)
+		values = append(values, d.Seconds())
+	}
+	return values
+}
+
+// Speeds calculates the upload speed in bytes per second from the growth of
+// the total contract size, like the benchmark does while running. current is
+// the speed since the previous sample, average is the average speed over the
+// measurement period of the run
+func (run *Run) Speeds() (current, average []float64) {
+	var period = time.Duration(run.Manifest.Config.MeasurementPeriod) * time.Second
+	if period == 0 {
+		period = 2 * time.Hour
+	}
+
+	var size = run.Float("contract_size_total")
+	current = make([]float64, len(size))
+	average = make([]float64, len(size))
+	for i := 1; i < len(size); i++ {
+		var dt = run.times[i].Sub(run.times[i-1]).Seconds()
+		if dt > 0 && size[i] >= size[i-1] {
+			current[i] = (size[i] - size[i-1]) / dt
+		}
+
+		// Average over the samples within the measurement period
+		var j = i
+		for j > 0 && run.times[i].Sub(run.times[j-1]) <= period {
+			j--
+		}
+		if dt = run.times[i].Sub(run.times[j]).Seconds(); dt > 0 && size[i] >= size[j] {
+			average[i] = (size[i] - size[j]) / dt
+		}
+	}
+	return current, average
+}
@@ -0,0 +1,206 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// blocksPerMonth is the number of Sia blocks in a 30 day month, with one block
+// every ten minutes
+const blocksPerMonth = 30 * 24 * 6
+
+// Summary contains the headline results of a run
+type Summary struct {
+	RunID      string        `json:"run_id"`
+	StartTime  time.Time     `json:"start_time"`
+	EndTime    time.Time     `json:"end_time"`
+	Duration   time.Duration `json:"duration_ns"`
+	ExitReason string        `json:"exit_reason"`
+	SiaVersion string        `json:"sia_version"`
+
+	// Block range of the test, zero if chain metrics were not collected
+	StartHeight uint64 `json:"start_height,omitempty"`
+	EndHeight   uint64 `json:"end_height,omitempty"`
+
+	FileCount         uint64 `json:"file_count"`
+	FileTotalBytes    uint64 `json:"file_total_bytes"`
+	ContractSizeTotal uint64 `json:"contract_size_total"`
+
+	// File bytes divided by contract bytes
+	Efficiency float64 `json:"efficiency"`
+
+	// Speeds in bytes per second. The average is over the whole run, the peak
+	// is the highest average over a measurement period
+	AverageSpeed float64 `json:"average_speed"`
+	PeakSpeed    float64 `json:"peak_speed"`
+
+	// Spending in siacoins
+	SpendingTotal    float64 `json:"spending_total_sc"`
+	SpendingStorage  float64 `json:"spending_storage_sc"`
+	SpendingUpload   float64 `json:"spending_upload_sc"`
+	SpendingDownload float64 `json:"spending_download_sc"`
+	SpendingFees     float64 `json:"spending_fees_sc"`
+
+	// Total spending per TB of file data, and storage spending per TB of file
+	// data per month of the allowance period. Zero if nothing was uploaded
+	CostPerTBUploaded float64 `json:"cost_per_tb_uploaded_sc"`
+	CostPerTBMonth    float64 `json:"cost_per_tb_month_sc"`
+
+	// Uploads which could not be submitted to the renter, and the highest
+	// number of stalled uploads seen at once
+	FailedUploads  uint64 `json:"failed_uploads"`
+	StalledUploads uint64 `json:"stalled_uploads"`
+
+	// Spending converted to a fiat currency with the rate at the end of the
+	// run. Empty if no conversion is configured
+	FiatCurrency          string  `json:"fiat_currency,omitempty"`
+	FiatRate              float64 `json:"fiat_rate,omitempty"`
+	SpendingTotalFiat     float64 `json:"spending_total_fiat,omitempty"`
+	CostPerTBUploadedFiat float64 `json:"cost_per_tb_uploaded_fiat,omitempty"`
+	CostPerTBMonthFiat    float64 `json:"cost_per_tb_month_fiat,omitempty"`
+
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// formatSiacoins formats an amount of siacoins, followed by the amount in the
+// fiat currency if conversion is enabled
+func (s Summary) formatSiacoins(v float64) string {
+	if s.FiatCurrency == "" {
+		return formatSiacoins(v)
+	}
+	return fmt.Sprintf("%s (%s)", formatSiacoins(v), formatFiat(v*s.FiatRate, s.FiatCurrency))
+}
+
+// Summarize calculates the headline results of a run
+func Summarize(run *Run) (s Summary) {
+	var last = run.Len() - 1
+	s.RunID = run.Manifest.RunID
+	s.StartTime = run.Manifest.StartTime
+	s.EndTime = run.Manifest.EndTime
+	s.ExitReason = run.Manifest.ExitReason
+	s.SiaVersion = run.Manifest.System.SiaVersion
+	s.StartHeight = run.Manifest.StartHeight
+	s.EndHeight = run.Manifest.EndHeight
+	s.Config = run.Config
+	if s.StartTime.IsZero() {
+		s.StartTime = run.times[0]
+	}
+	if s.EndTime.IsZero() {
+		s.EndTime = run.times[last]
+	}
+	s.Duration = s.EndTime.Sub(s.StartTime)
+
+	s.FileCount = uint64(run.Float("file_count")[last])
+	s.FileTotalBytes = uint64(run.Float("file_total_bytes")[last])
+	s.ContractSizeTotal = uint64(run.Float("contract_size_total")[last])
+	if s.ContractSizeTotal > 0 {
+		s.Efficiency = float64(s.FileTotalBytes) / float64(s.ContractSizeTotal)
+	}
+
+	var size = run.Float("contract_size_total")
+	if elapsed := run.times[last].Sub(run.times[0]).Seconds(); elapsed > 0 {
+		s.AverageSpeed = (size[last] - size[0]) / elapsed
+	}
+	_, average := run.Speeds()
+	_, s.PeakSpeed = bounds(average)
+
+	s.SpendingTotal = run.Siacoins("contract_spending_total")[last]
+	s.SpendingStorage = run.Siacoins("contract_storage_spending_total")[last]
+	s.SpendingUpload = run.Siacoins("contract_upload_spending_total")[last]
+	s.SpendingDownload = run.Siacoins("contract_download_spending_total")[last]
+	s.SpendingFees = run.Siacoins("contract_fee_spending_total")[last]
+
+	if tb := float64(s.FileTotalBytes) / 1e12; tb > 0 {
+		s.CostPerTBUploaded = s.SpendingTotal / tb
+
+		// Storage is paid for until the end of the allowance period
+		if months := run.Float("renter_allowance_period")[last] / blocksPerMonth; months > 0 {
+			s.CostPerTBMonth = s.SpendingStorage / tb / months
+		}
+	}
+
+	if run.Fiat != nil {
+		s.FiatCurrency = run.Fiat.Currency
+		s.FiatRate = run.Fiat.Rate(s.EndTime)
+		s.SpendingTotalFiat = s.SpendingTotal * s.FiatRate
+		s.CostPerTBUploadedFiat = s.CostPerTBUploaded * s.FiatRate
+		s.CostPerTBMonthFiat = s.CostPerTBMonth * s.FiatRate
+	}
+
+	s.FailedUploads = uint64(run.Float("file_uploads_failed_count")[last])
+	_, stalled := bounds(run.Float("file_uploads_stalled_count"))
+	s.StalledUploads = uint64(stalled)
+	return s
+}
+
+// WriteMarkdown writes the summary as a Markdown document, which can be pasted
+// in forum posts
+func WriteMarkdown(w io.Writer, s Summary) error {
+	var b = &errWriter{w: w}
+	b.printf("# Sia benchmark %s\n\n", s.RunID)
+	b.printf("| Result | Value |\n|---|---|\n")
+	for _, row := range headlineRows(s) {
+		b.printf("| %s | %s |\n", row.Name, row.Value)
+	}
+
+	if len(s.Config) > 0 {
+		// Numbers are decoded as is, large integers would be printed in
+		// scientific notation otherwise
+		var conf map[string]interface{}
+		var decoder = json.NewDecoder(bytes.NewReader(s.Config))
+		decoder.UseNumber()
+		if err := decoder.Decode(&conf); err != nil {
+			return err
+		}
+		var keys = make([]string, 0, len(conf))
+		for k := range conf {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b.printf("\n## Configuration\n\n| Option | Value |\n|---|---|\n")
+		for _, k := range keys {
+			b.printf("| %s | %v |\n", k, conf[k])
+		}
+	}
+	return b.err
+}
+
+// WriteSummary saves the summary of a run to summary.md and summary.json in
+// the run directory
+func WriteSummary(dir string, s Summary) error {
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "summary.json"), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(dir, "summary.md"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return WriteMarkdown(f, s)
+}
+
+// errWriter remembers the first error which occurred while writing, so it
+// doesn't need to be checked after every line
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...interface{}) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
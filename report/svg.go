@@ -0,0 +1,173 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+	"time"
+)
+
+// Chart dimensions in pixels
+const (
+	chartWidth   = 900
+	chartHeight  = 300
+	marginLeft   = 90
+	marginRight  = 20
+	marginTop    = 30
+	marginBottom = 40
+	chartTicks   = 5
+)
+
+// Colours used for the lines of a chart, in order
+var chartColours = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728",
+	"#9467bd", "#8c564b", "#e377c2", "#7f7f7f",
+}
+
+// Series is a line in a chart
+type Series struct {
+	Name   string
+	Values []float64
+
+	// X values of this series, if they differ from the X values of the chart
+	X []float64
+}
+
+func (s Series) xValues(c Chart) []float64 {
+	if s.X != nil {
+		return s.X
+	}
+	return c.X
+}
+
+// Chart is a line chart with a shared X axis for all series
+type Chart struct {
+	Title string
+
+	// X values of the samples, the labels are formatted with FormatX
+	X       []float64
+	FormatX func(float64) string
+
+	Series  []Series
+	FormatY func(float64) string
+}
+
+// SVG renders the chart as an inline SVG image
+func (c Chart) SVG() template.HTML {
+	var b strings.Builder
+	fmt.Fprintf(&b,
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" class="chart">`,
+		chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<text x="%d" y="18" class="title">%s</text>`,
+		marginLeft, template.HTMLEscapeString(c.Title))
+
+	var minX, maxX = math.Inf(1), math.Inf(-1)
+	var maxY float64
+	for _, s := range c.Series {
+		if _, max := bounds(s.Values); max > maxY {
+			maxY = max
+		}
+		if xs := s.xValues(c); len(xs) > 0 {
+			var min, max = bounds(xs)
+			minX, maxX = math.Min(minX, min), math.Max(maxX, max)
+		}
+	}
+	if math.IsInf(minX, 0) {
+		minX, maxX = 0, 0
+	}
+	if maxX <= minX {
+		maxX = minX + 1
+	}
+	if maxY <= 0 {
+		maxY = 1
+	}
+
+	var plotW = float64(chartWidth - marginLeft - marginRight)
+	var plotH = float64(chartHeight - marginTop - marginBottom)
+	var px = func(x float64) float64 { return marginLeft + (x-minX)/(maxX-minX)*plotW }
+	var py = func(y float64) float64 { return marginTop + plotH - y/maxY*plotH }
+
+	// Grid and axis labels
+	for i := 0; i <= chartTicks; i++ {
+		var y = maxY * float64(i) / chartTicks
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" class="grid"/>`,
+			marginLeft, py(y), chartWidth-marginRight, py(y))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" class="ylabel">%s</text>`,
+			marginLeft-6, py(y)+4, template.HTMLEscapeString(c.FormatY(y)))
+
+		var x = minX + (maxX-minX)*float64(i)/chartTicks
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" class="xlabel">%s</text>`,
+			px(x), chartHeight-marginBottom+18, template.HTMLEscapeString(c.FormatX(x)))
+	}
+
+	// Lines
+	for i, s := range c.Series {
+		var colour = chartColours[i%len(chartColours)]
+		var xs = s.xValues(c)
+		var points = make([]string, 0, len(s.Values))
+		for j, v := range s.Values {
+			if j < len(xs) {
+				points = append(points, fmt.Sprintf("%.1f,%.1f", px(xs[j]), py(v)))
+			}
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" stroke="%s" class="line"/>`,
+			strings.Join(points, " "), colour)
+
+		// Legend below the X axis
+		var lx = marginLeft + i*180
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`,
+			lx, chartHeight-12, colour)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" class="legend">%s</text>`,
+			lx+14, chartHeight-3, template.HTMLEscapeString(s.Name))
+	}
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// bounds returns the lowest and highest value of a slice
+func bounds(values []float64) (min, max float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		min, max = math.Min(min, v), math.Max(max, v)
+	}
+	return min, max
+}
+
+// formatData formats an amount of bytes with a decimal unit
+func formatData(v float64) string {
+	var units = []string{"B", "kB", "MB", "GB", "TB", "PB"}
+	var i int
+	for i = 0; math.Abs(v) >= 1000 && i < len(units)-1; i++ {
+		v /= 1000
+	}
+	return fmt.Sprintf("%.3g %s", v, units[i])
+}
+
+// formatSpeed formats a speed in bytes per second
+func formatSpeed(v float64) string {
+	return formatData(v) + "/s"
+}
+
+// formatSiacoins formats an amount of siacoins
+func formatSiacoins(v float64) string {
+	return fmt.Sprintf("%.4g SC", v)
+}
+
+// formatSeconds formats a number of seconds as a duration
+func formatSeconds(v float64) string {
+	var d = time.Duration(v * float64(time.Second))
+	if d >= time.Second {
+		return d.Round(time.Second).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// formatElapsed formats the elapsed time since the start of a run in hours
+func formatElapsed(v float64) string {
+	return fmt.Sprintf("%.1fh", v/3600)
+}
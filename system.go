@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"gitlab.com/NebulousLabs/Sia/node/api"
+)
+
+// buildVersion is the version of the benchmark tool, this can be set at build
+// time with -ldflags "-X main.buildVersion=v1.0.0"
+var buildVersion = ""
+
+// SystemInfo describes the machine the benchmark runs on. Most of this is read
+// from /proc, so on other operating systems some fields will be empty
+type SystemInfo struct {
+	OS            string `json:"os"`
+	Arch          string `json:"arch"`
+	Kernel        string `json:"kernel"`
+	CPUModel      string `json:"cpu_model"`
+	CPUCores      int    `json:"cpu_cores"`
+	MemoryTotal   uint64 `json:"memory_total"`
+	UploadsFSType string `json:"uploads_fs_type"`
+	UploadsDevice string `json:"uploads_device"`
+
+	GoVersion        string `json:"go_version"`
+	BenchmarkVersion string `json:"benchmark_version"`
+	SiaVersion       string `json:"sia_version"`
+	SiaGitRevision   string `json:"sia_git_revision"`
+	SiaBuildTime     string `json:"sia_build_time"`
+}
+
+// systemFingerprint collects information about the hardware and software the
+// benchmark is running on
+func systemFingerprint(uploadsDir string, siaVersion api.DaemonVersionGet) (info SystemInfo) {
+	info.OS = runtime.GOOS
+	info.Arch = runtime.GOARCH
+	info.CPUCores = runtime.NumCPU()
+	info.GoVersion = runtime.Version()
+	info.BenchmarkVersion = benchmarkVersion()
+	info.SiaVersion = siaVersion.Version
+	info.SiaGitRevision = siaVersion.GitRevision
+	info.SiaBuildTime = siaVersion.BuildTime
+
+	if kernel, err := ioutil.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		info.Kernel = strings.TrimSpace(string(kernel))
+	}
+
+	forEachLine("/proc/cpuinfo", func(line string) bool {
+		if key, value := splitKeyValue(line); key == "model name" {
+			info.CPUModel = value
+			return false
+		}
+		return true
+	})
+
+	forEachLine("/proc/meminfo", func(line string) bool {
+		if key, value := splitKeyValue(line); key == "MemTotal" {
+			// The value is formatted as '16318856 kB', where kB means KiB
+			kb, _ := strconv.ParseUint(strings.TrimSuffix(value, " kB"), 10, 64)
+			info.MemoryTotal = kb * 1024
+			return false
+		}
+		return true
+	})
+
+	// Find the mount point which contains the uploads dir. The one with the
+	// longest path is the most specific one
+	var longestMount string
+	forEachLine("/proc/mounts", func(line string) bool {
+		var fields = strings.Fields(line)
+		if len(fields) < 3 {
+			return true
+		}
+		var mount = fields[1]
+		if len(mount) > len(longestMount) &&
+			(uploadsDir == mount || strings.HasPrefix(uploadsDir, strings.TrimSuffix(mount, "/")+"/")) {
+			longestMount = mount
+			info.UploadsDevice = fields[0]
+			info.UploadsFSType = fields[2]
+		}
+		return true
+	})
+
+	return info
+}
+
+// benchmarkVersion returns the version of the benchmark tool. If it was not
+// set at build time the module version is used
+func benchmarkVersion() string {
+	if buildVersion != "" {
+		return buildVersion
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+// forEachLine calls fn for every line in the file until it returns false.
+// Errors are ignored, if the file can't be read fn is never called
+func forEachLine(path string, fn func(line string) bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var scanner = bufio.NewScanner(f)
+	for scanner.Scan() {
+		if !fn(scanner.Text()) {
+			return
+		}
+	}
+}
+
+// splitKeyValue splits a line in the format 'key: value' and trims the spaces
+func splitKeyValue(line string) (key, value string) {
+	var parts = strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Fornax96/sia_benchmark/report"
+)
+
+// validate checks all configuration fields and the constraints between them.
+// It returns every problem it finds so they can be fixed at once, instead of
+// one per run
+func (conf Configuration) validate() (problems []string) {
+	var problem = func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if conf.SiaAPIURL == "" {
+		problem("sia_api_url is empty")
+	}
+
+	// Measurement settings
+	if conf.MeasurementInterval == 0 {
+		problem("measurement_interval must be at least 1 second")
+	}
+	if conf.MeasurementPeriod < conf.MeasurementInterval {
+		problem(
+			"measurement_period (%d) must be at least as long as measurement_interval (%d)",
+			conf.MeasurementPeriod, conf.MeasurementInterval)
+	}
+	if conf.UploadLatencyWindow == 0 {
+		problem("upload_latency_window must be at least 1 second")
+	}
+	if conf.StalledUploadIntervals < 0 {
+		problem("stalled_upload_intervals can't be negative")
+	}
+	if conf.StalledUploadPolicy != "report" && conf.StalledUploadPolicy != "resubmit" {
+		problem(
+			"stalled_upload_policy must be \"report\" or \"resubmit\", not \"%s\"",
+			conf.StalledUploadPolicy)
+	}
+	if conf.HostDBSnapshotInterval != 0 && conf.HostDBSnapshotInterval < conf.MeasurementInterval {
+		problem(
+			"hostdb_snapshot_interval (%d) must be 0 or at least as long as measurement_interval (%d)",
+			conf.HostDBSnapshotInterval, conf.MeasurementInterval)
+	}
+	if conf.SiadPID < 0 {
+		problem("siad_pid can't be negative")
+	}
+	if conf.ResultsDir == "" {
+		problem("results_dir is empty")
+	}
+	if conf.FiatRate < 0 {
+		problem("fiat_rate can't be negative")
+	}
+	if conf.FiatCurrency != "" {
+		if _, err := report.LoadFiatRates(conf.FiatCurrency, conf.FiatRate, conf.FiatRatesFile); err != nil {
+			problem("fiat conversion can't be used: %s", err)
+		}
+	}
+	if conf.LoggingVerbosity < 0 || conf.LoggingVerbosity > 4 {
+		problem("logging_verbosity must be between 0 and 4, not %d", conf.LoggingVerbosity)
+	}
+
+	// The upload settings don't matter in watch only mode
+	if conf.WatchOnly {
+		return problems
+	}
+
+	if conf.Allowance <= 0 {
+		problem("allowance must be more than 0 SC")
+	}
+	if conf.AllowancePeriod <= 0 {
+		problem("allowance_period must be more than 0 blocks")
+	}
+	if conf.HostCount <= 0 {
+		problem("host_count must be more than 0")
+	}
+	if conf.FileDataPieces == 0 {
+		problem("file_data_pieces must be at least 1")
+	}
+	if conf.FileDataPieces+conf.FileParityPieces > uint64(conf.HostCount) {
+		problem(
+			"file_data_pieces + file_parity_pieces (%d) is more than host_count (%d), "+
+				"uploads will never reach full redundancy",
+			conf.FileDataPieces+conf.FileParityPieces, conf.HostCount)
+	}
+	if conf.FileSize == 0 {
+		problem("file_size must be more than 0 bytes")
+	}
+	if conf.MaxConcurrentUploads == 0 {
+		problem("max_concurrent_uploads must be at least 1")
+	}
+	if conf.Seed != "" {
+		if seed, err := hex.DecodeString(conf.Seed); err != nil || len(seed) != 32 {
+			problem("seed must be empty or 32 bytes encoded as 64 hex characters")
+		}
+	}
+
+	if err := siaPathLayout(conf, "run_id").Validate(); err != nil {
+		problem("siapath settings are invalid: %s", err)
+	}
+	if strings.Trim(siaPathPrefix(conf), "/") == "" {
+		problem(
+			"siapath_root \"%s\" must start with a directory before {run_id}, "+
+				"otherwise the benchmark files can't be told apart from other files on the renter",
+			conf.SiaPathRoot)
+	}
+
+	if conf.FileUploadsDir == "" {
+		problem("file_uploads_dir is empty")
+	} else if dir, err := os.Stat(conf.FileUploadsDir); err != nil {
+		problem("file_uploads_dir can't be used: %s", err)
+	} else if !dir.IsDir() {
+		problem("file_uploads_dir %s is not a directory", conf.FileUploadsDir)
+	}
+
+	return problems
+}
+
+// mustValidate validates the configuration and exits if there are problems
+func mustValidate(conf Configuration) {
+	var problems = conf.validate()
+	if len(problems) == 0 {
+		return
+	}
+
+	// Printed directly instead of logged, so the problems are shown regardless
+	// of the logging verbosity
+	fmt.Fprintf(os.Stderr, "The configuration has %d problem(s):\n", len(problems))
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, " - %s\n", p)
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Fornax96/sia_benchmark/collector"
+	"github.com/Fornax96/sia_benchmark/report"
+	"github.com/Fornaxian/log"
+	"gitlab.com/NebulousLabs/Sia/modules"
+)
+
+// Number of points the web dashboard keeps for the charts. With the default
+// interval of one minute this is a week
+const webHistory = 7 * 24 * 60
+
+// webPoint contains the metrics which are shown in the charts of the web
+// dashboard for one interval
+type webPoint struct {
+	Time          int64   `json:"time"`
+	FileBytes     uint64  `json:"file_bytes"`
+	ContractBytes uint64  `json:"contract_bytes"`
+	CurrentSpeed  uint64  `json:"current_speed"`
+	AverageSpeed  uint64  `json:"average_speed"`
+	Spending      float64 `json:"spending_sc"`
+	Efficiency    float64 `json:"efficiency"`
+	Uploading     uint64  `json:"uploads_in_progress"`
+	Stalled       uint64  `json:"uploads_stalled"`
+}
+
+// webUpload is a file which is being uploaded
+type webUpload struct {
+	SiaPath  string  `json:"sia_path"`
+	Size     uint64  `json:"size"`
+	Progress float64 `json:"progress"`
+	Stuck    bool    `json:"stuck"`
+}
+
+// webExitStatus shows how close the test is to meeting its exit conditions
+type webExitStatus struct {
+	WatchOnly bool `json:"watch_only"`
+
+	// The speed condition is only checked after the first measurement period
+	SpeedCheckActive bool   `json:"speed_check_active"`
+	AverageSpeed     uint64 `json:"average_speed"`
+	MinUploadRate    uint64 `json:"min_upload_rate"`
+
+	FileTotalBytes       uint64 `json:"file_total_bytes"`
+	SuccessSizeThreshold uint64 `json:"success_size_threshold"`
+	ETA                  string `json:"eta"`
+}
+
+// webStatus is sent to the browser every interval
+type webStatus struct {
+	RunID     string        `json:"run_id"`
+	Point     webPoint      `json:"point"`
+	Files     uint64        `json:"file_count"`
+	Failed    uint64        `json:"uploads_failed"`
+	Contracts int           `json:"contracts_active"`
+	Spending  string        `json:"spending"`
+	Uploads   []webUpload   `json:"uploads"`
+	Exit      webExitStatus `json:"exit"`
+}
+
+// webDashboard serves a web page with live charts of the benchmark. New
+// metrics are pushed to the browsers with server-sent events
+type webDashboard struct {
+	runID string
+
+	mutex   sync.Mutex
+	history []webPoint
+	status  []byte
+	clients map[chan []byte]struct{}
+}
+
+// startWebDashboard starts the web dashboard on the given address
+func startWebDashboard(address, runID string) (*webDashboard, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	var w = &webDashboard{
+		runID:   runID,
+		clients: make(map[chan []byte]struct{}),
+	}
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/", w.serveIndex)
+	mux.HandleFunc("/status", w.serveStatus)
+	mux.HandleFunc("/events", w.serveEvents)
+
+	var server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Error("Web dashboard stopped: %s", err)
+		}
+	}()
+	log.Info("Web dashboard is available at http://%s/", listener.Addr())
+	return w, nil
+}
+
+// publish sends the metrics of an interval to all connected browsers
+func (w *webDashboard) publish(
+	metrics collector.Metrics,
+	bwCurrent, bwAverage uint64,
+	uploads []modules.FileInfo,
+	exitChecked bool,
+	conf Configuration,
+	fiat *report.FiatRates,
+) {
+	var status = webStatus{
+		RunID: w.runID,
+		Point: webPoint{
+			Time:          metrics.Timestamp.Unix(),
+			FileBytes:     metrics.FileTotalBytes,
+			ContractBytes: metrics.ContractSizeTotal,
+			CurrentSpeed:  bwCurrent,
+			AverageSpeed:  bwAverage,
+			Spending:      report.Siacoins(metrics.ContractSpendingTotal),
+			Efficiency:    metrics.Efficiency,
+			Uploading:     metrics.FileUploadsInProgressCount,
+			Stalled:       metrics.FileUploadsStalledCount,
+		},
+		Files:     metrics.FileCount,
+		Failed:    metrics.FileUploadsFailedCount,
+		Contracts: metrics.ContractCountActive,
+		Spending:  metrics.ContractSpendingTotal.HumanString(),
+		Uploads:   []webUpload{},
+		Exit: webExitStatus{
+			WatchOnly:            conf.WatchOnly,
+			SpeedCheckActive:     exitChecked,
+			AverageSpeed:         bwAverage,
+			MinUploadRate:        conf.MinUploadRate,
+			FileTotalBytes:       metrics.FileTotalBytes,
+			SuccessSizeThreshold: conf.SuccessSizeThreshold,
+			ETA:                  formatETA(metrics, conf),
+		},
+	}
+	if fiat != nil {
+		status.Spending += " (" + fiat.Format(status.Point.Spending, metrics.Timestamp) + ")"
+	}
+
+	sort.Slice(uploads, func(i, j int) bool { return uploads[i].SiaPath.String() < uploads[j].SiaPath.String() })
+	for _, file := range uploads {
+		status.Uploads = append(status.Uploads, webUpload{
+			SiaPath:  file.SiaPath.String(),
+			Size:     file.Filesize,
+			Progress: file.UploadProgress,
+			Stuck:    file.Stuck,
+		})
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		log.Warn("Could not encode web dashboard status: %s", err)
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.status = data
+	w.history = append(w.history, status.Point)
+	if len(w.history) > webHistory {
+		w.history = w.history[len(w.history)-webHistory:]
+	}
+	for client := range w.clients {
+		// Slow clients miss an update instead of holding up the benchmark
+		select {
+		case client <- data:
+		default:
+		}
+	}
+}
+
+func (w *webDashboard) serveIndex(rw http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(rw, r)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(rw, webDashboardPage)
+}
+
+// serveStatus returns the latest status as JSON, for use in scripts
+func (w *webDashboard) serveStatus(rw http.ResponseWriter, r *http.Request) {
+	w.mutex.Lock()
+	var status = w.status
+	w.mutex.Unlock()
+	if status == nil {
+		http.Error(rw, "no metrics have been collected yet", http.StatusServiceUnavailable)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(status)
+}
+
+// serveEvents streams the status to the browser. A new connection first
+// receives the history for the charts and the latest status
+func (w *webDashboard) serveEvents(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+
+	var client = make(chan []byte, 8)
+	w.mutex.Lock()
+	history, err := json.Marshal(w.history)
+	var status = w.status
+	w.clients[client] = struct{}{}
+	w.mutex.Unlock()
+	defer func() {
+		w.mutex.Lock()
+		delete(w.clients, client)
+		w.mutex.Unlock()
+	}()
+	if err != nil {
+		log.Warn("Could not encode web dashboard history: %s", err)
+		return
+	}
+
+	fmt.Fprintf(rw, "event: history\ndata: %s\n\n", history)
+	if status != nil {
+		fmt.Fprintf(rw, "event: status\ndata: %s\n\n", status)
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case data := <-client:
+			fmt.Fprintf(rw, "event: status\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// webDashboardPage is the web dashboard. The charts are drawn as SVG with a
+// few lines of JavaScript, so it works without access to the internet
+const webDashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Sia benchmark</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { padding: 4px 12px; border-bottom: 1px solid #ddd; text-align: left; }
+.ok { color: #2a7; } .bad { color: #c33; } .muted { color: #888; }
+svg { width: 100%; margin-bottom: 2em; }
+svg .title { font-size: 16px; font-weight: bold; }
+svg .grid { stroke: #ddd; }
+svg .line { fill: none; stroke-width: 2; }
+svg .label { font-size: 11px; }
+progress { width: 160px; }
+</style>
+</head>
+<body>
+<h1>Sia benchmark <span id="run"></span></h1>
+<p id="state" class="muted">Connecting...</p>
+<table id="summary"></table>
+<h2>Exit conditions</h2>
+<table id="exit"></table>
+<div id="charts"></div>
+<h2>Uploads in progress (<span id="count">0</span>)</h2>
+<table id="uploads"></table>
+<script>
+"use strict";
+var colors = ["#1f77b4", "#ff7f0e", "#2ca02c", "#d62728"];
+var samples = [];
+var charts = [
+	{title: "Contract size vs file size", format: data, series: [
+		["Contract size", function(p) { return p.contract_bytes; }],
+		["File size", function(p) { return p.file_bytes; }]]},
+	{title: "Upload speed", format: speed, series: [
+		["Current", function(p) { return p.current_speed; }],
+		["Average", function(p) { return p.average_speed; }]]},
+	{title: "Spending", format: function(v) { return v.toPrecision(3) + " SC"; }, series: [
+		["Total", function(p) { return p.spending_sc; }]]},
+	{title: "Uploads", format: function(v) { return v.toFixed(0); }, series: [
+		["In progress", function(p) { return p.uploads_in_progress; }],
+		["Stalled", function(p) { return p.uploads_stalled; }]]}
+];
+
+function data(v) {
+	var units = ["B", "kB", "MB", "GB", "TB", "PB"], i = 0;
+	while (v >= 1000 && i < units.length - 1) { v /= 1000; i++; }
+	return v.toPrecision(3) + " " + units[i];
+}
+function speed(v) { return data(v) + "/s"; }
+function esc(s) {
+	return String(s).replace(/[&<>"]/g, function(c) { return "&#" + c.charCodeAt(0) + ";"; });
+}
+function row(cells, tag) {
+	tag = tag || "td";
+	return "<tr>" + cells.map(function(c) { return "<" + tag + ">" + c + "</" + tag + ">"; }).join("") + "</tr>";
+}
+
+function drawChart(chart) {
+	var w = 900, h = 300, left = 80, top = 30, bottom = 30;
+	var t0 = samples.length ? samples[0].time : 0, t1 = samples.length ? samples[samples.length - 1].time : 1;
+	var max = 0;
+	chart.series.forEach(function(s) {
+		samples.forEach(function(p) { max = Math.max(max, s[1](p)); });
+	});
+	if (max === 0) { max = 1; }
+	if (t1 === t0) { t1 = t0 + 1; }
+	var x = function(t) { return left + (t - t0) / (t1 - t0) * (w - left - 10); };
+	var y = function(v) { return h - bottom - v / max * (h - top - bottom); };
+
+	var svg = '<svg viewBox="0 0 ' + w + ' ' + h + '"><text class="title" x="' + left + '" y="18">' + chart.title + '</text>';
+	for (var i = 0; i <= 4; i++) {
+		var v = max * i / 4;
+		svg += '<line class="grid" x1="' + left + '" x2="' + (w - 10) + '" y1="' + y(v) + '" y2="' + y(v) + '"/>';
+		svg += '<text class="label" text-anchor="end" x="' + (left - 5) + '" y="' + (y(v) + 4) + '">' + chart.format(v) + '</text>';
+		var t = t0 + (t1 - t0) * i / 4;
+		svg += '<text class="label" text-anchor="middle" x="' + x(t) + '" y="' + (h - 10) + '">' + ((t - t0) / 3600).toFixed(1) + ' h</text>';
+	}
+	chart.series.forEach(function(s, i) {
+		var line = samples.map(function(p) { return x(p.time) + "," + y(s[1](p)); }).join(" ");
+		svg += '<polyline class="line" stroke="' + colors[i] + '" points="' + line + '"/>';
+		svg += '<text class="label" fill="' + colors[i] + '" x="' + (left + 200 + i * 150) + '" y="18">' + s[0] + '</text>';
+	});
+	return svg + "</svg>";
+}
+
+function update(s) {
+	var p = s.point, e = s.exit;
+	document.getElementById("run").textContent = s.run_id;
+	document.getElementById("state").textContent = "Last update " + new Date(p.time * 1000).toLocaleString();
+	document.getElementById("summary").innerHTML =
+		row(["Files", s.file_count + " (" + s.uploads_failed + " failed)"]) +
+		row(["File data", data(p.file_bytes)]) +
+		row(["Contract data", data(p.contract_bytes)]) +
+		row(["Redundancy efficiency", (p.efficiency * 100).toFixed(2) + "%"]) +
+		row(["Current speed", speed(p.current_speed)]) +
+		row(["Active contracts", s.contracts_active]) +
+		row(["Spending", esc(s.spending)]);
+
+	var rate, size;
+	if (e.watch_only) {
+		rate = '<span class="muted">not checked in watch only mode</span>';
+	} else if (!e.speed_check_active) {
+		rate = '<span class="muted">not checked during the first measurement period</span>';
+	} else {
+		rate = '<span class="' + (e.average_speed >= e.min_upload_rate ? "ok" : "bad") + '">' +
+			speed(e.average_speed) + " average, minimum is " + speed(e.min_upload_rate) + "</span>";
+	}
+	if (e.success_size_threshold > 0) {
+		size = '<progress max="' + e.success_size_threshold + '" value="' + e.file_total_bytes + '"></progress> ' +
+			data(e.file_total_bytes) + " of " + data(e.success_size_threshold) + ", ETA " + esc(e.eta);
+	} else {
+		size = '<span class="muted">no success threshold configured</span>';
+	}
+	document.getElementById("exit").innerHTML = row(["Upload speed", rate]) + row(["File data", size]);
+
+	document.getElementById("count").textContent = s.uploads.length;
+	document.getElementById("uploads").innerHTML = row(["File", "Size", "Progress"], "th") +
+		s.uploads.map(function(u) {
+			return row([esc(u.sia_path) + (u.stuck ? ' <span class="bad">stuck</span>' : ""), data(u.size),
+				'<progress max="100" value="' + u.progress + '"></progress> ' + u.progress.toFixed(1) + "%"]);
+		}).join("");
+}
+
+function redraw() {
+	document.getElementById("charts").innerHTML = charts.map(drawChart).join("");
+}
+
+var events = new EventSource("events");
+events.addEventListener("history", function(ev) {
+	samples = JSON.parse(ev.data) || [];
+	redraw();
+});
+events.addEventListener("status", function(ev) {
+	var s = JSON.parse(ev.data);
+	if (!samples.length || samples[samples.length - 1].time !== s.point.time) {
+		samples.push(s.point);
+	}
+	update(s);
+	redraw();
+});
+events.onerror = function() {
+	document.getElementById("state").textContent = "Connection lost, reconnecting...";
+};
+</script>
+</body>
+</html>
+`